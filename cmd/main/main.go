@@ -1,68 +1,223 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
+	"syscall"
+	"time"
+	// so the tz parameter works on hosts without a time zone database
+	_ "time/tzdata"
 
 	"github.com/danecwalker/gotrack/pkg/analytics"
+	"github.com/danecwalker/gotrack/pkg/config"
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/geo"
+	"github.com/danecwalker/gotrack/pkg/ingest"
+	"github.com/danecwalker/gotrack/pkg/realtime"
+	"github.com/danecwalker/gotrack/pkg/salt"
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/store/postgres"
 	"github.com/danecwalker/gotrack/pkg/store/sqlite"
 	"github.com/danecwalker/gotrack/pkg/tag"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
-const port int = 3000
+//go:embed *.tmpl
+var templates embed.FS
+
+func openStore(driver string, dsn string) (store.DBClient, error) {
+	switch driver {
+	case "sqlite":
+		return sqlite.NewSqlite(dsn)
+	case "postgres":
+		return postgres.NewPostgres(dsn)
+	default:
+		return nil, fmt.Errorf("unknown db driver: %s", driver)
+	}
+}
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal().Err(err).Msg("invalid config")
+	}
+
+	// the level applies to the application log and the query log alike
+	zerolog.SetGlobalLevel(cfg.Level())
+	event.SessionTimeout = int(time.Duration(cfg.SessionTimeout).Seconds())
+	tag.AllowedOrigins = cfg.CorsOrigins
+	event.TrustedProxies, err = cfg.ProxyNets()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid config")
+	}
+	event.BotFilter = event.FilterMode(cfg.Bots.Filter)
+	event.BlockedNetworks, err = cfg.BlockedNets()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid config")
+	}
+	if cfg.Bots.CrawlerPatterns != "" {
+		if err := event.LoadCrawlerPatterns(cfg.Bots.CrawlerPatterns); err != nil {
+			log.Fatal().Err(err).Msg("failed to load crawler patterns")
+		}
+	}
+
+	if cfg.ReferrerSources != "" {
+		if err := event.LoadReferrerSources(cfg.ReferrerSources); err != nil {
+			log.Fatal().Err(err).Msg("failed to load referrer sources")
+		}
+	}
+
+	analytics.ExchangeRates = &store.ExchangeRates{
+		Currency: cfg.Revenue.Currency,
+		Rates:    cfg.Revenue.ExchangeRates,
+	}
+
+	if cfg.GeoIPDB != "" {
+		geoip, err := geo.Open(cfg.GeoIPDB)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open the geoip db")
+		}
+		defer geoip.Close()
+		event.GeoIP = geoip
+	}
+
+	t := template.Must(template.ParseFS(templates, "*.tmpl"))
+
 	r := http.NewServeMux()
-	// s, err := sqlite.NewSqlite(":memory:")
-	s, err := sqlite.NewSqlite("./cmd/main/analytics.db")
+	// s, err := openStore("sqlite", ":memory:")
+	s, err := openStore(cfg.DB.Driver, cfg.DB.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open the db")
+	}
+
+	salts, err := salt.NewKeeper(s, time.Duration(cfg.SessionTimeout))
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal().Err(err).Msg("failed to load the salts")
 	}
+	event.Salts = salts
 
-	// wait for ctrl+c to close db connection
-	// defer func() {
-	// 	_ = s.Close()
-	// }()
+	queue := ingest.NewQueue(s, ingest.DefaultOptions)
+	hub := realtime.NewHub()
 
-	r.HandleFunc("/e", analytics.HandleTrackEvent(s))
+	r.HandleFunc("/e", analytics.HandleTrackEvent(queue, hub))
+	r.HandleFunc("/api/v1/sites", analytics.Sites(s))
+	r.HandleFunc("/api/v1/goals", analytics.Goals(s))
+	r.HandleFunc("/api/v1/funnels", analytics.Funnels(s))
+	r.HandleFunc("/api/v1/funnels/stats", analytics.GetFunnelStats(s))
 	r.HandleFunc("/api/v1/stats", analytics.GetStats(s))
 	r.HandleFunc("/api/v1/graph", analytics.GraphStats(s))
+	r.HandleFunc("/api/v1/retention", analytics.GetRetention(s))
+	r.HandleFunc("/api/v1/realtime", analytics.GetRealtime(s))
+	r.HandleFunc("/api/v1/realtime/stream", analytics.RealtimeStream(s, hub))
+	r.HandleFunc("/api/v1/props", analytics.GetProps(s))
+	r.HandleFunc("/api/v1/revenues", analytics.GetRevenues(s))
+	r.HandleFunc("/api/v1/revenue", analytics.GetRevenue(s))
+	r.HandleFunc("/api/v1/revenue/goals", analytics.GetRevenueByGoal(s))
+	r.HandleFunc("/api/v1/revenue/sources", analytics.GetRevenueBySource(s))
+	r.HandleFunc("/api/v1/revenue/campaigns", analytics.GetRevenueByCampaign(s))
+	r.HandleFunc("/api/v1/filtered", analytics.GetFiltered(s))
+	r.HandleFunc("/api/v1/breakdown/countries", analytics.GetCountries(s))
+	r.HandleFunc("/api/v1/breakdown/regions", analytics.GetRegions(s))
+	r.HandleFunc("/api/v1/breakdown/sources", analytics.GetSources(s))
+	r.HandleFunc("/api/v1/breakdown/referrers", analytics.GetReferrers(s))
+	r.HandleFunc("/api/v1/breakdown/utm-sources", analytics.GetUTMSources(s))
+	r.HandleFunc("/api/v1/breakdown/utm-mediums", analytics.GetUTMMediums(s))
+	r.HandleFunc("/api/v1/breakdown/utm-campaigns", analytics.GetUTMCampaigns(s))
+	r.HandleFunc("/api/v1/breakdown/utm-terms", analytics.GetUTMTerms(s))
+	r.HandleFunc("/api/v1/breakdown/utm-contents", analytics.GetUTMContents(s))
+	r.HandleFunc("/api/v1/breakdown/browsers", analytics.GetBrowsers(s))
+	r.HandleFunc("/api/v1/breakdown/browser-versions", analytics.GetBrowserVersions(s))
+	r.HandleFunc("/api/v1/breakdown/operating-systems", analytics.GetOperatingSystems(s))
+	r.HandleFunc("/api/v1/breakdown/os-versions", analytics.GetOSVersions(s))
+	r.HandleFunc("/api/v1/breakdown/screen-types", analytics.GetScreenTypes(s))
+	r.HandleFunc("/api/v1/breakdown/languages", analytics.GetLanguages(s))
+	r.HandleFunc("/api/v1/breakdown/pages", analytics.GetPages(s))
+	r.HandleFunc("/api/v1/breakdown/entry-pages", analytics.GetEntryPages(s))
+	r.HandleFunc("/api/v1/breakdown/exit-pages", analytics.GetExitPages(s))
+	r.HandleFunc("/api/v1/breakdown/outbound-links", analytics.GetOutboundLinks(s))
+	r.HandleFunc("/api/v1/breakdown/file-downloads", analytics.GetFileDownloads(s))
+	r.HandleFunc("/api/v1/breakdown/broken-links", analytics.GetBrokenLinks(s))
+	r.HandleFunc("/api/v1/breakdown/broken-link-referrers", analytics.GetBrokenLinkReferrers(s))
 	r.HandleFunc("/tag/", tag.HandleTag)
 
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Accept-CH", "Sec-CH-UA-Platform, Sec-CH-UA, Sec-CH-UA-Mobile")
 		w.WriteHeader(http.StatusOK)
-		t := template.Must(template.New("page.html.tmpl").ParseFiles("cmd/main/page.html.tmpl"))
-		t.Execute(w, map[string]interface{}{})
+		t.ExecuteTemplate(w, "page.html.tmpl", map[string]interface{}{})
 	})
 
 	r.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		t := template.Must(template.New("store.html.tmpl").ParseFiles("cmd/main/store.html.tmpl"))
-		t.Execute(w, map[string]interface{}{})
+		t.ExecuteTemplate(w, "store.html.tmpl", map[string]interface{}{})
 	})
 
-	// Start the server on port 3000.
-	addr := "127.0.0.1"
-	addrs, _ := net.InterfaceAddrs()
-	for _, a := range addrs {
+	// Start the server on the configured address, when no host is given
+	// show the LAN address so the server is easy to reach from other devices.
+	host, port, _ := net.SplitHostPort(cfg.Addr)
+	if host == "" {
+		host = "127.0.0.1"
 		re := regexp.MustCompile(`^(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})(?:\:\d+|\/\d+)?`)
-		if matches := re.FindStringSubmatch(a.String()); len(matches) > 0 && (matches[1] != "127.0.0.1" || matches[1] == "localhost") {
-			addr = re.FindStringSubmatch(a.String())[1]
-			fmt.Println(addr)
+		addrs, _ := net.InterfaceAddrs()
+		for _, a := range addrs {
+			if matches := re.FindStringSubmatch(a.String()); len(matches) > 0 && matches[1] != "127.0.0.1" {
+				host = matches[1]
+			}
 		}
 	}
-	log.Printf("Starting server on http://%s:%d", addr, port)
-	if os.Getenv("GO_ENV") == "dev" {
-		log.Println("Running in dev mode")
+	log.Info().Msgf("Starting server on http://%s", net.JoinHostPort(host, port))
+	if cfg.IsDev() {
+		log.Info().Msg("Running in dev mode")
 	}
-	err = http.ListenAndServe(fmt.Sprintf(":%d", port), r)
-	if err != nil {
-		log.Fatal(err)
+
+	srv := &http.Server{
+		Addr:    cfg.Addr,
+		Handler: r,
+	}
+	// end the realtime streams so shutdown does not wait on them
+	srv.RegisterOnShutdown(hub.Close)
+
+	// wait for ctrl+c, then stop accepting requests, drain the ingest queue
+	// and close the db connection
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go salts.Run(ctx)
+
+	idle := make(chan struct{})
+	go func() {
+		defer close(idle)
+		<-ctx.Done()
+		log.Info().Msg("Shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("failed to shut down the server")
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal().Err(err).Msg("server failed")
+	}
+	<-idle
+
+	if err := queue.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close the ingest queue")
+	}
+	if err := s.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close the db")
 	}
 }
@@ -4,8 +4,11 @@ go 1.21.3
 
 require (
 	github.com/evanw/esbuild v0.19.11
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.20
+	github.com/maxmind/mmdbwriter v1.0.0
 	github.com/mileusna/useragent v1.3.4
+	github.com/oschwald/maxminddb-golang v1.12.0
 	github.com/rs/zerolog v1.32.0
 	github.com/simukti/sqldb-logger v0.0.0-20230108155151-646c1a075551
 	github.com/simukti/sqldb-logger/logadapter/zerologadapter v0.0.0-20230108155151-646c1a075551
@@ -14,5 +17,6 @@ require (
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	go4.org/netipx v0.0.0-20220812043211-3cc044ffd68d // indirect
 	golang.org/x/sys v0.16.0 // indirect
 )
@@ -0,0 +1,205 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type breakdownFunc func(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error)
+
+var breakdownSorts = []string{"visitors", "visits", "pageviews", "bounce_rate", "visit_duration", "custom_events", "conversions"}
+
+// GetCountries returns the visitors for each country.
+func GetCountries(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetCountries)
+}
+
+// GetRegions returns the visitors for each region.
+func GetRegions(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetRegions)
+}
+
+// GetSources returns the visitors for each referrer source, such as "Google".
+func GetSources(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetSources)
+}
+
+// GetReferrers returns the visitors for each full referrer of the "source"
+// parameter.
+func GetReferrers(store store.DBClient) http.HandlerFunc {
+	return drillDown("source", store.GetReferrers)
+}
+
+// GetUTMSources returns the visitors for each utm_source, visits are
+// attributed to the parameters of their first event.
+func GetUTMSources(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetUTMSources)
+}
+
+// GetUTMMediums returns the visitors for each utm_medium.
+func GetUTMMediums(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetUTMMediums)
+}
+
+// GetUTMCampaigns returns the visitors for each utm_campaign.
+func GetUTMCampaigns(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetUTMCampaigns)
+}
+
+// GetUTMTerms returns the visitors for each utm_term.
+func GetUTMTerms(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetUTMTerms)
+}
+
+// GetUTMContents returns the visitors for each utm_content.
+func GetUTMContents(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetUTMContents)
+}
+
+// GetBrowsers returns the visitors for each browser.
+func GetBrowsers(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetBrowsers)
+}
+
+// GetBrowserVersions returns the visitors for each version of the "browser"
+// parameter.
+func GetBrowserVersions(store store.DBClient) http.HandlerFunc {
+	return drillDown("browser", store.GetBrowserVersions)
+}
+
+// GetOperatingSystems returns the visitors for each operating system.
+func GetOperatingSystems(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetOperatingSystems)
+}
+
+// GetOSVersions returns the visitors for each version of the "os" parameter.
+func GetOSVersions(store store.DBClient) http.HandlerFunc {
+	return drillDown("os", store.GetOSVersions)
+}
+
+// GetScreenTypes returns the visitors for each screen type, e.g. "mobile".
+func GetScreenTypes(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetScreenTypes)
+}
+
+// GetLanguages returns the visitors for each browser language.
+func GetLanguages(store store.DBClient) http.HandlerFunc {
+	return breakdown(store.GetLanguages)
+}
+
+type drillDownFunc func(site string, parent string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error)
+
+// drillDown is a breakdown of the rows of another breakdown, param is the
+// required parameter that names the parent row.
+func drillDown(param string, fn drillDownFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has(param) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("missing " + param))
+			return
+		}
+		parent := q.Get(param)
+
+		breakdown(func(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+			return fn(site, parent, from, to, filters, opts)
+		})(w, r)
+	}
+}
+
+func breakdown(fn breakdownFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		opts, ok := parseListOptions(w, r, breakdownSorts...)
+		if !ok {
+			return
+		}
+
+		rows, err := fn(site, from, to, filters, opts)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(rows)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+// parseListOptions reads the "sort", "limit" and 1-based "page" parameters,
+// sort must be one of sorts and defaults to the first.
+func parseListOptions(w http.ResponseWriter, r *http.Request, sorts ...string) (store.ListOptions, bool) {
+	q := r.URL.Query()
+	opts := store.ListOptions{
+		Sort:  sorts[0],
+		Limit: store.DefaultLimit,
+	}
+
+	if s := q.Get("sort"); s != "" {
+		if !slices.Contains(sorts, s) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid sort, must be one of " + strings.Join(sorts, ", ")))
+			return opts, false
+		}
+		opts.Sort = s
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid limit, must be between 1 and 1000"))
+			return opts, false
+		}
+		opts.Limit = limit
+	}
+
+	if p := q.Get("page"); p != "" {
+		page, err := strconv.Atoi(p)
+		if err != nil || page < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid page"))
+			return opts, false
+		}
+		opts.Offset = (page - 1) * opts.Limit
+	}
+
+	return opts, true
+}
@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+// GetFiltered returns how many events were filtered as automated traffic,
+// grouped by reason.
+func GetFiltered(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		counts, err := store.GetFilteredCounts(site, from, to)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(counts)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,170 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type funnelRequest struct {
+	Name  string             `json:"name"`
+	Steps []store.FunnelStep `json:"steps"`
+	// Window is in seconds, zero is no limit.
+	Window int `json:"window"`
+}
+
+// Funnels lists and creates the funnels of a site, a funnel is updated with PUT
+// and deleted with DELETE given its "id".
+func Funnels(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		var (
+			res interface{}
+			err error
+		)
+
+		switch r.Method {
+		case http.MethodGet:
+			res, err = store.GetFunnels(site)
+		case http.MethodPost, http.MethodPut:
+			if r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("400 bad request"))
+				return
+			}
+
+			req := &funnelRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			funnel, ok := newFunnel(w, req)
+			if !ok {
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				res, err = store.CreateFunnel(site, funnel)
+				break
+			}
+
+			if funnel.ID, ok = parseID(w, r); !ok {
+				return
+			}
+			res, err = store.UpdateFunnel(site, funnel)
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := store.DeleteFunnel(site, id); err != nil {
+				w.WriteHeader(errorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			tag.ApplyCors(w, r)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(b)
+	}
+}
+
+// GetFunnelStats returns how many sessions reached each step of the funnel
+// "id" in order, with the conversion and drop-off rates of each step.
+func GetFunnelStats(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := parseID(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		stats, err := store.GetFunnelStats(site, id, from, to, filters)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(stats)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+func newFunnel(w http.ResponseWriter, req *funnelRequest) (*store.Funnel, bool) {
+	funnel := &store.Funnel{
+		Name:   req.Name,
+		Steps:  req.Steps,
+		Window: req.Window,
+	}
+	if err := funnel.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return nil, false
+	}
+	return funnel, true
+}
@@ -0,0 +1,149 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type goalRequest struct {
+	Name      string `json:"name"`
+	EventName string `json:"event_name"`
+	PagePath  string `json:"page_path"`
+}
+
+// Goals lists and creates the goals of a site, a goal is updated with PUT and
+// deleted with DELETE given its "id".
+func Goals(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		var (
+			res interface{}
+			err error
+		)
+
+		switch r.Method {
+		case http.MethodGet:
+			res, err = store.GetGoals(site)
+		case http.MethodPost, http.MethodPut:
+			if r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("400 bad request"))
+				return
+			}
+
+			req := &goalRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			goal, ok := newGoal(w, req)
+			if !ok {
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				res, err = store.CreateGoal(site, goal)
+				break
+			}
+
+			if goal.ID, ok = parseID(w, r); !ok {
+				return
+			}
+			res, err = store.UpdateGoal(site, goal)
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := store.DeleteGoal(site, id); err != nil {
+				w.WriteHeader(errorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			tag.ApplyCors(w, r)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(b)
+	}
+}
+
+func newGoal(w http.ResponseWriter, req *goalRequest) (*store.Goal, bool) {
+	goal := &store.Goal{
+		Name:      req.Name,
+		EventName: req.EventName,
+		PagePath:  req.PagePath,
+	}
+	if err := goal.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return nil, false
+	}
+	return goal, true
+}
+
+// parseID reads the "id" parameter of a goal or funnel.
+func parseID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing or invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// goalStats returns the conversions of every goal in rng, compared with cmp
+// when it is not nil.
+func goalStats(db store.DBClient, site string, rng dateRange, cmp *dateRange, filters store.Filters) ([]*store.GoalDiff, error) {
+	goals, err := db.GetGoalStats(site, rng.From, rng.To, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev []*store.GoalStats
+	if cmp != nil {
+		prev, err = db.GetGoalStats(site, cmp.From, cmp.To, filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return store.CompareGoals(goals, prev), nil
+}
@@ -0,0 +1,105 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type linksFunc func(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error)
+
+var linkSorts = []string{"visitors", "events"}
+
+// GetOutboundLinks returns the visitors and clicks for each link to another
+// site.
+func GetOutboundLinks(store store.DBClient) http.HandlerFunc {
+	return links(store.GetOutboundLinks)
+}
+
+// GetFileDownloads returns the visitors and downloads for each file.
+func GetFileDownloads(store store.DBClient) http.HandlerFunc {
+	return links(store.GetFileDownloads)
+}
+
+// GetBrokenLinks returns the visitors and 404s for each url that was not
+// found.
+func GetBrokenLinks(store store.DBClient) http.HandlerFunc {
+	return links(store.GetBrokenLinks)
+}
+
+// GetBrokenLinkReferrers returns the visitors and 404s for each page that
+// linked to the "url" parameter.
+func GetBrokenLinkReferrers(store store.DBClient) http.HandlerFunc {
+	return linkDrillDown("url", store.GetBrokenLinkReferrers)
+}
+
+type linkDrillDownFunc func(site string, parent string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error)
+
+// linkDrillDown is drillDown for the link reports.
+func linkDrillDown(param string, fn linkDrillDownFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has(param) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("missing " + param))
+			return
+		}
+		parent := q.Get(param)
+
+		links(func(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+			return fn(site, parent, from, to, filters, opts)
+		})(w, r)
+	}
+}
+
+func links(fn linksFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		opts, ok := parseListOptions(w, r, linkSorts...)
+		if !ok {
+			return
+		}
+
+		rows, err := fn(site, from, to, filters, opts)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(rows)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
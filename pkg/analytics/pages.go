@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type pagesFunc func(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.PageStats, error)
+
+var pageSorts = []string{"visitors", "pageviews", "bounce_rate", "time_on_page", "scroll_depth"}
+
+// GetPages returns the visitors, pageviews, bounce rate, time on page and
+// scroll depth of every page.
+func GetPages(store store.DBClient) http.HandlerFunc {
+	return pages(store.GetPages)
+}
+
+// GetEntryPages is like GetPages but only counts the first page of a visit.
+func GetEntryPages(store store.DBClient) http.HandlerFunc {
+	return pages(store.GetEntryPages)
+}
+
+// GetExitPages is like GetPages but only counts the last page of a visit.
+func GetExitPages(store store.DBClient) http.HandlerFunc {
+	return pages(store.GetExitPages)
+}
+
+func pages(fn pagesFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		opts, ok := parseListOptions(w, r, pageSorts...)
+		if !ok {
+			return
+		}
+
+		rows, err := fn(site, from, to, filters, opts)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(rows)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,249 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// dateRange is the time a report covers, From and To are both included.
+type dateRange struct {
+	From time.Time
+	To   time.Time
+	// Location is the "tz" time zone that calendar periods and the buckets of
+	// graphs are aligned to.
+	Location *time.Location
+	// Period is the named period, or "custom" for a from and to range.
+	Period string
+	// Interval is the graph bucket that suits the length of the range.
+	Interval store.Interval
+}
+
+// dateLayout is the layout of from and to when they are dates in tz rather
+// than times.
+const dateLayout = "2006-01-02"
+
+// readRange reads the range of a report from the request. The range is either
+// "from" and "to", each a date or an RFC 3339 time, where a date "to" includes
+// the whole day, or a named "period":
+//
+//   - hour, 24h, 7d and 30d end at the unix "date" parameter, or now
+//   - today, yesterday, this_month, last_month, year_to_date and
+//     last_12_months are calendar periods in the "tz" time zone
+//   - all has no start
+//
+// The default is 24h in UTC. The "interval" of graphs is chosen to suit the
+// length of the range unless it is given.
+func readRange(r *http.Request, now time.Time) (dateRange, error) {
+	rng, err := readPeriod(r, now)
+	if err != nil {
+		return dateRange{}, err
+	}
+
+	if i := r.URL.Query().Get("interval"); i != "" {
+		if rng.Interval, err = store.ParseInterval(i); err != nil {
+			return dateRange{}, err
+		}
+	}
+	return rng, nil
+}
+
+func readPeriod(r *http.Request, now time.Time) (dateRange, error) {
+	q := r.URL.Query()
+
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return dateRange{}, fmt.Errorf("invalid tz %q", tz)
+		}
+		loc = l
+	}
+
+	if q.Has("from") || q.Has("to") {
+		if p := q.Get("period"); p != "" && p != "custom" {
+			return dateRange{}, errors.New("period cannot be used with from and to")
+		}
+		if q.Has("date") {
+			return dateRange{}, errors.New("date cannot be used with from and to")
+		}
+
+		from, _, err := parseTime(q.Get("from"), loc)
+		if err != nil {
+			return dateRange{}, fmt.Errorf("invalid from: %w", err)
+		}
+		to, isDate, err := parseTime(q.Get("to"), loc)
+		if err != nil {
+			return dateRange{}, fmt.Errorf("invalid to: %w", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		if to.Before(from) {
+			return dateRange{}, errors.New("from must not be after to")
+		}
+		return dateRange{From: from, To: to, Location: loc, Period: "custom", Interval: intervalFor(from, to)}, nil
+	}
+
+	if d := q.Get("date"); d != "" {
+		pd, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return dateRange{}, fmt.Errorf("invalid date %q", d)
+		}
+		now = time.Unix(pd, 0)
+	}
+
+	period := q.Get("period")
+	if period == "" {
+		period = "24h"
+	}
+
+	rng := dateRange{To: now, Location: loc, Period: period}
+	today := store.IntervalDay.Truncate(now, loc)
+	month := store.IntervalMonth.Truncate(now, loc)
+	switch period {
+	case "hour":
+		rng.From = now.Add(-time.Hour)
+	case "24h":
+		rng.From = now.Add(-24 * time.Hour)
+	case "7d":
+		rng.From = now.AddDate(0, 0, -7)
+	case "30d":
+		rng.From = now.AddDate(0, 0, -30)
+	case "today":
+		rng.From = today
+	case "yesterday":
+		rng.From, rng.To = today.AddDate(0, 0, -1), today.Add(-time.Nanosecond)
+	case "this_month":
+		rng.From = month
+	case "last_month":
+		rng.From, rng.To = month.AddDate(0, -1, 0), month.Add(-time.Nanosecond)
+	case "year_to_date":
+		rng.From = time.Date(now.In(loc).Year(), time.January, 1, 0, 0, 0, 0, loc)
+	case "last_12_months":
+		rng.From = month.AddDate(0, -11, 0)
+	case "all":
+		rng.Interval = store.IntervalMonth
+		return rng, nil
+	default:
+		return dateRange{}, fmt.Errorf("invalid period %q", period)
+	}
+
+	rng.Interval = intervalFor(rng.From, rng.To)
+	return rng, nil
+}
+
+// readComparison reads the "compare" parameter, the period rng is compared
+// with, which is one of:
+//
+//   - previous_period, the range of the same length just before
+//   - year_over_year, the same range a year earlier
+//   - custom, from "compare_from" to "compare_to" read like from and to
+//   - none
+//
+// It returns nil when there is nothing to compare with, which is also the
+// case for the previous period and year of a range without a start.
+func readComparison(r *http.Request, rng dateRange, def string) (*dateRange, error) {
+	q := r.URL.Query()
+	compare := q.Get("compare")
+	if compare == "" {
+		compare = def
+	}
+	if compare != "custom" && (q.Has("compare_from") || q.Has("compare_to")) {
+		return nil, errors.New("compare_from and compare_to need compare=custom")
+	}
+
+	cmp := rng
+	switch compare {
+	case "none":
+		return nil, nil
+	case "previous_period":
+		if rng.From.IsZero() {
+			return nil, nil
+		}
+		cmp.To = rng.From.Add(-time.Nanosecond)
+		cmp.From = cmp.To.Add(-rng.To.Sub(rng.From))
+	case "year_over_year":
+		if rng.From.IsZero() {
+			return nil, nil
+		}
+		cmp.From, cmp.To = rng.From.AddDate(-1, 0, 0), rng.To.AddDate(-1, 0, 0)
+	case "custom":
+		from, _, err := parseTime(q.Get("compare_from"), rng.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compare_from: %w", err)
+		}
+		to, isDate, err := parseTime(q.Get("compare_to"), rng.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compare_to: %w", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		if to.Before(from) {
+			return nil, errors.New("compare_from must not be after compare_to")
+		}
+		cmp.From, cmp.To = from, to
+	default:
+		return nil, fmt.Errorf("invalid compare %q", compare)
+	}
+	return &cmp, nil
+}
+
+// parseTime parses s as a date at midnight in loc, or as an RFC 3339 time, and
+// reports whether it was a date.
+func parseTime(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(dateLayout, s, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q is not a date or an RFC 3339 time", s)
+	}
+	return t, false, nil
+}
+
+// intervalFor returns the graph bucket for a range, hours up to two days and
+// days up to about three months.
+func intervalFor(from, to time.Time) store.Interval {
+	switch d := to.Sub(from); {
+	case d <= 48*time.Hour:
+		return store.IntervalHour
+	case d <= 92*24*time.Hour:
+		return store.IntervalDay
+	default:
+		return store.IntervalMonth
+	}
+}
+
+// parseDateRange reads the range of a report, an invalid range is a 400.
+func parseDateRange(w http.ResponseWriter, r *http.Request) (dateRange, bool) {
+	rng, err := readRange(r, time.Now().UTC())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return dateRange{}, false
+	}
+	return rng, true
+}
+
+// parseComparison reads the period to compare with, an invalid one is a 400.
+func parseComparison(w http.ResponseWriter, r *http.Request, rng dateRange, def string) (*dateRange, bool) {
+	cmp, err := readComparison(r, rng, def)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return nil, false
+	}
+	return cmp, true
+}
+
+// parseRange reads the range of a report when only its bounds are needed.
+func parseRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	rng, ok := parseDateRange(w, r)
+	return rng.From, rng.To, ok
+}
@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+func mustParse(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestReadRange(t *testing.T) {
+	now := mustParse(t, "2024-03-15T10:30:00Z")
+
+	tests := []struct {
+		query    string
+		from     string
+		to       string
+		tz       string
+		period   string
+		interval store.Interval
+	}{
+		{"", "2024-03-14T10:30:00Z", "2024-03-15T10:30:00Z", "UTC", "24h", store.IntervalHour},
+		{"period=hour", "2024-03-15T09:30:00Z", "2024-03-15T10:30:00Z", "UTC", "hour", store.IntervalHour},
+		{"period=7d", "2024-03-08T10:30:00Z", "2024-03-15T10:30:00Z", "UTC", "7d", store.IntervalDay},
+		{"period=30d&interval=week", "2024-02-14T10:30:00Z", "2024-03-15T10:30:00Z", "UTC", "30d", store.IntervalWeek},
+		{"period=hour&date=1700000000", "2023-11-14T21:13:20Z", "2023-11-14T22:13:20Z", "UTC", "hour", store.IntervalHour},
+		// it is already the 15th in Auckland, which is 13 hours ahead
+		{"period=today&tz=Pacific/Auckland", "2024-03-14T11:00:00Z", "2024-03-15T10:30:00Z", "Pacific/Auckland", "today", store.IntervalHour},
+		{"period=yesterday&tz=Pacific/Auckland", "2024-03-13T11:00:00Z", "2024-03-14T10:59:59.999999999Z", "Pacific/Auckland", "yesterday", store.IntervalHour},
+		// the clocks go forward in London on the 31st, the day starts in GMT
+		{"period=today&tz=Europe/London&date=1711886400", "2024-03-31T00:00:00Z", "2024-03-31T12:00:00Z", "Europe/London", "today", store.IntervalHour},
+		{"period=this_month", "2024-03-01T00:00:00Z", "2024-03-15T10:30:00Z", "UTC", "this_month", store.IntervalDay},
+		{"period=last_month", "2024-02-01T00:00:00Z", "2024-02-29T23:59:59.999999999Z", "UTC", "last_month", store.IntervalDay},
+		{"period=year_to_date", "2024-01-01T00:00:00Z", "2024-03-15T10:30:00Z", "UTC", "year_to_date", store.IntervalDay},
+		{"period=last_12_months", "2023-04-01T00:00:00Z", "2024-03-15T10:30:00Z", "UTC", "last_12_months", store.IntervalMonth},
+		{"period=all", "0001-01-01T00:00:00Z", "2024-03-15T10:30:00Z", "UTC", "all", store.IntervalMonth},
+		// a date "to" includes the whole day in tz
+		{"from=2024-03-01&to=2024-03-02&tz=America/New_York", "2024-03-01T05:00:00Z", "2024-03-03T04:59:59.999999999Z", "America/New_York", "custom", store.IntervalHour},
+		{"from=2024-03-01T00:00:00Z&to=2024-03-01T12:00:00%2B02:00", "2024-03-01T00:00:00Z", "2024-03-01T10:00:00Z", "UTC", "custom", store.IntervalHour},
+		{"from=2024-01-01&to=2024-12-31&period=custom", "2024-01-01T00:00:00Z", "2024-12-31T23:59:59.999999999Z", "UTC", "custom", store.IntervalMonth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rng, err := readRange(httptest.NewRequest("GET", "/?"+tt.query, nil), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from := mustParse(t, tt.from); !rng.From.Equal(from) {
+				t.Errorf("from = %v, want %v", rng.From.UTC(), from)
+			}
+			if to := mustParse(t, tt.to); !rng.To.Equal(to) {
+				t.Errorf("to = %v, want %v", rng.To.UTC(), to)
+			}
+			if rng.Location.String() != tt.tz {
+				t.Errorf("tz = %s, want %s", rng.Location, tt.tz)
+			}
+			if rng.Period != tt.period || rng.Interval != tt.interval {
+				t.Errorf("period, interval = %s, %s, want %s, %s", rng.Period, rng.Interval, tt.period, tt.interval)
+			}
+		})
+	}
+}
+
+func TestReadRangeErrors(t *testing.T) {
+	now := mustParse(t, "2024-03-15T10:30:00Z")
+
+	for _, query := range []string{
+		"tz=Mars/Olympus_Mons",
+		"period=fortnight",
+		"period=7d&interval=year",
+		"date=yesterday",
+		"from=2024-03-01&to=2024-03-02&period=7d",
+		"from=2024-03-01&to=2024-03-02&date=1700000000",
+		"from=2024-03-02&to=2024-03-01",
+		"from=01/03/2024&to=2024-03-02",
+		"from=2024-03-01",
+	} {
+		if _, err := readRange(httptest.NewRequest("GET", "/?"+query, nil), now); err == nil {
+			t.Errorf("readRange(%q) did not fail", query)
+		}
+	}
+}
+
+func TestReadComparison(t *testing.T) {
+	day := dateRange{
+		From:     mustParse(t, "2024-03-10T00:00:00Z"),
+		To:       mustParse(t, "2024-03-10T23:59:59.999999999Z"),
+		Location: time.UTC,
+		Period:   "custom",
+		Interval: store.IntervalHour,
+	}
+	all := dateRange{To: day.To, Location: time.UTC, Period: "all", Interval: store.IntervalMonth}
+
+	tests := []struct {
+		name  string
+		query string
+		rng   dateRange
+		def   string
+		// from and to are empty when there is nothing to compare with
+		from string
+		to   string
+	}{
+		{"default", "", day, "previous_period", "2024-03-09T00:00:00Z", "2024-03-09T23:59:59.999999999Z"},
+		{"default none", "", day, "none", "", ""},
+		{"none", "compare=none", day, "previous_period", "", ""},
+		{"previous period", "compare=previous_period", day, "none", "2024-03-09T00:00:00Z", "2024-03-09T23:59:59.999999999Z"},
+		{"year over year", "compare=year_over_year", day, "none", "2023-03-10T00:00:00Z", "2023-03-10T23:59:59.999999999Z"},
+		{"custom", "compare=custom&compare_from=2024-02-01&compare_to=2024-02-02", day, "none", "2024-02-01T00:00:00Z", "2024-02-02T23:59:59.999999999Z"},
+		{"previous period of all", "compare=previous_period", all, "none", "", ""},
+		{"year over year of all", "compare=year_over_year", all, "none", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp, err := readComparison(httptest.NewRequest("GET", "/?"+tt.query, nil), tt.rng, tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.from == "" {
+				if cmp != nil {
+					t.Errorf("comparison = %v to %v, want none", cmp.From, cmp.To)
+				}
+				return
+			}
+			if cmp == nil {
+				t.Fatal("no comparison")
+			}
+			if from := mustParse(t, tt.from); !cmp.From.Equal(from) {
+				t.Errorf("from = %v, want %v", cmp.From.UTC(), from)
+			}
+			if to := mustParse(t, tt.to); !cmp.To.Equal(to) {
+				t.Errorf("to = %v, want %v", cmp.To.UTC(), to)
+			}
+			if cmp.Interval != tt.rng.Interval || cmp.Location != tt.rng.Location {
+				t.Errorf("comparison is bucketed by %s in %s, want %s in %s", cmp.Interval, cmp.Location, tt.rng.Interval, tt.rng.Location)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		"compare=last_week",
+		"compare_from=2024-02-01&compare_to=2024-02-02",
+		"compare=previous_period&compare_to=2024-02-02",
+		"compare=custom&compare_from=2024-02-02&compare_to=2024-02-01",
+		"compare=custom&compare_from=2024-02-01",
+	} {
+		if _, err := readComparison(httptest.NewRequest("GET", "/?"+query, nil), day, "none"); err == nil {
+			t.Errorf("readComparison(%q) did not fail", query)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/realtime"
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+const (
+	// realtimeLimit is how many pages and sources realtime reports list.
+	realtimeLimit = 10
+	// liveDelay is how long the stream waits after an event before it sends
+	// the live counts, so the ingest queue has written the event by then and
+	// a burst of events is sent as one update.
+	liveDelay = 2 * time.Second
+	// liveInterval is how often the stream sends the live counts without new
+	// events, so visitors that left drop out of them.
+	liveInterval = 30 * time.Second
+)
+
+// GetRealtime returns the current visitors, the sessions with an event in the
+// last five minutes, with their top pages and sources.
+func GetRealtime(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		live, err := currentVisitors(store, site)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(live)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+// RealtimeStream streams the events of a site as Server-Sent Events as they
+// are tracked. Each tracked event is an "event" message, and "live" messages
+// hold the counts of GetRealtime, sent on connect, shortly after new events
+// and every half minute.
+func RealtimeStream(store store.DBClient, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("streaming is not supported"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		// subscribe before the first counts so no event falls in between, to
+		// the domain as events are published with it
+		sub := hub.Subscribe(event.NormalizeDomain(site))
+		defer hub.Unsubscribe(sub)
+
+		live, err := currentVisitors(store, site)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeEvent(w, "live", live); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(liveInterval)
+		defer ticker.Stop()
+		delay := time.NewTimer(liveDelay)
+		delay.Stop()
+		pending := false
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, "event", e); err != nil {
+					return
+				}
+				if !pending {
+					delay.Reset(liveDelay)
+					pending = true
+				}
+			case <-delay.C:
+				pending = false
+				if live, err = currentVisitors(store, site); err != nil {
+					return
+				}
+				if err := writeEvent(w, "live", live); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if live, err = currentVisitors(store, site); err != nil {
+					return
+				}
+				if err := writeEvent(w, "live", live); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func currentVisitors(db store.DBClient, site string) (*store.Realtime, error) {
+	return db.GetRealtime(site, time.Now().UTC().Add(-store.RealtimeWindow), realtimeLimit)
+}
+
+// writeEvent writes v as the JSON data of a Server-Sent Event of type name.
+func writeEvent(w http.ResponseWriter, name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, b)
+	return err
+}
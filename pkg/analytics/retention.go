@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+// GetRetention returns the retention matrix of the returning visitors of a
+// site, with a cohort for every "interval" of the range by the start of their
+// first visit. It is empty unless the site privacy is "returning".
+func GetRetention(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		rng, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		interval, ok := parseRetentionInterval(w, r)
+		if !ok {
+			return
+		}
+
+		// all time starts when the site was added
+		if rng.From.IsZero() {
+			st, err := store.GetSite(site)
+			if err != nil {
+				w.WriteHeader(errorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+			rng.From = st.CreatedAt
+		}
+
+		ret, err := store.GetRetention(site, rng.From, rng.To, interval, rng.Location, filters)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(ret)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+func parseRetentionInterval(w http.ResponseWriter, r *http.Request) (store.Interval, bool) {
+	interval, err := store.ParseRetentionInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return "", false
+	}
+	return interval, true
+}
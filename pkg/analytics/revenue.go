@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+// ExchangeRates converts revenue to the reporting currency, it is set from the
+// config at startup.
+var ExchangeRates = &store.ExchangeRates{Currency: "USD"}
+
+type revenueFunc func(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error)
+
+// GetRevenue returns the total revenue in the reporting currency and the
+// average revenue of each event with revenue.
+func GetRevenue(store store.DBClient) http.HandlerFunc {
+	return revenue(store.GetTotalRevenue, revenueTotal)
+}
+
+// GetRevenueByGoal returns the revenue of the events that reach each goal.
+func GetRevenueByGoal(store store.DBClient) http.HandlerFunc {
+	return revenueBreakdown(store.GetRevenueByGoal)
+}
+
+// GetRevenueBySource returns the revenue for each referrer source.
+func GetRevenueBySource(store store.DBClient) http.HandlerFunc {
+	return revenueBreakdown(store.GetRevenueBySource)
+}
+
+// GetRevenueByCampaign returns the revenue for each utm_campaign.
+func GetRevenueByCampaign(store store.DBClient) http.HandlerFunc {
+	return revenueBreakdown(store.GetRevenueByCampaign)
+}
+
+func revenueBreakdown(fn revenueFunc) http.HandlerFunc {
+	return revenue(fn, func(totals []*store.RevenueTotal) interface{} {
+		return ExchangeRates.Convert(totals)
+	})
+}
+
+func revenueTotal(totals []*store.RevenueTotal) interface{} {
+	return ExchangeRates.Total(totals)
+}
+
+func revenue(fn revenueFunc, convert func([]*store.RevenueTotal) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		from, to, ok := parseRange(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		totals, err := fn(site, from, to, filters)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(convert(totals))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
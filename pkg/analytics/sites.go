@@ -0,0 +1,125 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/tag"
+)
+
+type siteRequest struct {
+	Domain  string `json:"domain"`
+	Privacy string `json:"privacy"`
+}
+
+// Sites lists and creates sites, the privacy of a site is changed with PUT.
+func Sites(store store.DBClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			res interface{}
+			err error
+		)
+
+		switch r.Method {
+		case http.MethodGet:
+			res, err = store.GetSites()
+		case http.MethodPost, http.MethodPut:
+			if r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("400 bad request"))
+				return
+			}
+
+			req := &siteRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if req.Domain == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("missing domain"))
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				res, err = store.CreateSite(req.Domain)
+				break
+			}
+
+			privacy, ok := parsePrivacy(w, req.Privacy)
+			if !ok {
+				return
+			}
+			res, err = store.SetSitePrivacy(req.Domain, privacy)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("405 method not allowed"))
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tag.ApplyCors(w, r)
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(b)
+	}
+}
+
+func parsePrivacy(w http.ResponseWriter, s string) (store.Privacy, bool) {
+	if s == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing privacy"))
+		return "", false
+	}
+	privacy, err := store.ParsePrivacy(s)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return "", false
+	}
+	return privacy, true
+}
+
+// errorStatus maps an error returned by the store to the status code sent
+// back to the client.
+func errorStatus(err error) int {
+	if errors.Is(err, store.ErrSiteNotFound) || errors.Is(err, store.ErrGoalNotFound) || errors.Is(err, store.ErrFunnelNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, store.ErrSiteExists) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, store.ErrTooManyBuckets) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func parseSite(w http.ResponseWriter, r *http.Request) (string, bool) {
+	site := r.URL.Query().Get("site")
+	if site == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing site"))
+		return "", false
+	}
+	return site, true
+}
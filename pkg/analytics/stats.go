@@ -2,10 +2,10 @@ package analytics
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"time"
+	"net/url"
 
 	"github.com/danecwalker/gotrack/pkg/store"
 	"github.com/danecwalker/gotrack/pkg/tag"
@@ -19,48 +19,56 @@ func GetStats(store store.DBClient) http.HandlerFunc {
 			return
 		}
 
-		d := r.URL.Query().Get("date")
-		now := time.Now().UTC()
-		if d != "" {
-			pd, err := strconv.ParseInt(d, 10, 64)
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(err.Error()))
-				return
-			}
-			n := time.Unix(pd, 0)
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(err.Error()))
-				return
-			}
-			now = n
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		rng, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		cmp, ok := parseComparison(w, r, rng, "previous_period")
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
 		}
 
-		duration := parsePeriod(r.URL.Query().Get("period"))
-		last := now.Add(-duration)
-		fmt.Println(last, now)
-		stats, err := store.GetStats(last, now)
+		stats, err := store.GetStats(site, rng.From, rng.To, filters)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(errorStatus(err))
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		prev_stats, err := store.GetStats(last.Add(-duration), last)
+		res := stats.Calculate(nil)
+		if cmp != nil {
+			prev_stats, err := store.GetStats(site, cmp.From, cmp.To, filters)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			res = stats.Calculate(prev_stats)
+		}
+
+		res.Goals, err = goalStats(store, site, rng, cmp, filters)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		res := stats.Calculate(prev_stats)
-
-		tag.ApplyCors(w)
+		tag.ApplyCors(w, r)
 		if r.Header.Get("HX-Request") == "true" {
 			w.Header().Add("Content-Type", "text/html")
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`<div hx-get="/api/v1/stats?period=30d" hx-swap="outerHTML">`))
+			w.Write([]byte(`<div hx-get="/api/v1/stats?site=` + url.QueryEscape(site) + `&period=30d" hx-swap="outerHTML">`))
 			w.Write([]byte(`<h2>` + fmt.Sprint(res.PageViews.Value) + "<span style='font-size: 1rem; margin-left: 2rem;'>" + fmt.Sprint(res.PageViews.Change) + `</span></h2>`))
 			w.Write([]byte(`<h2>` + fmt.Sprint(res.Visitors.Value) + "<span style='font-size: 1rem; margin-left: 2rem;'>" + fmt.Sprint(res.Visitors.Change) + `</span></h2>`))
 			w.Write([]byte(`<h2>` + fmt.Sprint(res.Bounces.Value) + "<span style='font-size: 1rem; margin-left: 2rem;'>" + fmt.Sprint(res.Bounces.Change) + `</span></h2>`))
@@ -81,6 +89,8 @@ func GetStats(store store.DBClient) http.HandlerFunc {
 	}
 }
 
+// GraphStats returns the "metrics" of each "interval" of the range, see
+// store.ParseMetrics and store.ParseInterval.
 func GraphStats(store store.DBClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -89,25 +99,61 @@ func GraphStats(store store.DBClient) http.HandlerFunc {
 			return
 		}
 
-		now := time.Now().UTC()
+		site, ok := parseSite(w, r)
+		if !ok {
+			return
+		}
+
+		filters, ok := parseFilters(w, r)
+		if !ok {
+			return
+		}
+
+		rng, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		cmp, ok := parseComparison(w, r, rng, "none")
+		if !ok {
+			return
+		}
+
+		metrics, ok := parseMetrics(w, r)
+		if !ok {
+			return
+		}
 
-		switch r.URL.Query().Get("period") {
-		case "24h":
-			now = time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, time.UTC)
-		default:
-			now = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		// the graph of all time starts when the site was added
+		if rng.From.IsZero() {
+			st, err := store.GetSite(site)
+			if err != nil {
+				w.WriteHeader(errorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+			rng.From = st.CreatedAt
 		}
 
-		duration := parsePeriod(r.URL.Query().Get("period"))
-		last := now.Add(-duration)
-		gr, err := store.GetViewsAndVisits(r.URL.Query().Get("period"), last, now)
+		gr, err := store.GetGraph(site, rng.From, rng.To, rng.Interval, rng.Location, metrics, filters)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(errorStatus(err))
 			w.Write([]byte(err.Error()))
 			return
 		}
+		gr.Period = rng.Period
+
+		if cmp != nil {
+			gr.Comparison, err = store.GetGraph(site, cmp.From, cmp.To, rng.Interval, rng.Location, metrics, filters)
+			if err != nil {
+				w.WriteHeader(errorStatus(err))
+				w.Write([]byte(err.Error()))
+				return
+			}
+			gr.Comparison.Period = "compare"
+		}
 
-		tag.ApplyCors(w)
+		tag.ApplyCors(w, r)
 
 		b, err := json.Marshal(gr)
 		if err != nil {
@@ -121,17 +167,40 @@ func GraphStats(store store.DBClient) http.HandlerFunc {
 	}
 }
 
-func parsePeriod(p string) time.Duration {
-	switch p {
-	case "hour":
-		return time.Hour
-	case "24h":
-		return 24 * time.Hour
-	case "7d":
-		return 7 * 24 * time.Hour
-	case "30d":
-		return 30 * 24 * time.Hour
-	default:
-		return 24 * time.Hour
+// parseMetrics reads the comma separated "metrics" of a graph.
+func parseMetrics(w http.ResponseWriter, r *http.Request) ([]store.Metric, bool) {
+	metrics, err := store.ParseMetrics(r.URL.Query().Get("metrics"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return nil, false
+	}
+	return metrics, true
+}
+
+// parseFilters reads the "filters" parameter, an invalid filter is reported
+// as a JSON store.FilterError so clients can point at the expression.
+func parseFilters(w http.ResponseWriter, r *http.Request) (store.Filters, bool) {
+	filters, err := store.ParseFilters(r.URL.Query().Get("filters"))
+	if err != nil {
+		var fe *store.FilterError
+		if !errors.As(err, &fe) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return nil, false
+		}
+
+		b, err := json.Marshal(fe)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return nil, false
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(b)
+		return nil, false
 	}
+
+	return filters, true
 }
@@ -1,22 +1,36 @@
 package analytics
 
 import (
+	"errors"
+	"mime"
 	"net/http"
 
 	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/ingest"
+	"github.com/danecwalker/gotrack/pkg/realtime"
 	"github.com/danecwalker/gotrack/pkg/store"
 	"github.com/danecwalker/gotrack/pkg/tag"
 )
 
-func HandleTrackEvent(store store.DBClient) http.HandlerFunc {
+// HandleTrackEvent queues tracked events to be written, and publishes the
+// interactions that are part of a visit to hub for the realtime stream.
+func HandleTrackEvent(queue *ingest.Queue, hub *realtime.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			tag.ApplyCors(w, r)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			w.Write([]byte("405 method not allowed"))
 			return
 		}
 
-		if r.Header.Get("Content-Type") != "application/json" {
+		// beacons sent as the page is left are text/plain, which needs no
+		// preflight, but hold the same JSON
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" && ct != "text/plain" {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte("400 bad request"))
 			return
@@ -30,19 +44,35 @@ func HandleTrackEvent(store store.DBClient) http.HandlerFunc {
 			return
 		}
 
-		if err := store.InsertSession(s); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		// events for unknown sites would be queued only to be skipped
+		if err := queue.CheckSite(we.Domain); err != nil {
+			w.WriteHeader(errorStatus(err))
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		if err := store.InsertEvent(we); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		rec := &store.Record{
+			Session: s,
+			Event:   we,
+			Discard: we.BotReason != "" && event.BotFilter == event.FilterDrop,
+		}
+		switch err := queue.Enqueue(rec); {
+		case err == nil:
+			// only events that will be written are streamed, so the stream
+			// agrees with the reports
+			if we.BotReason == "" && !we.IsEngagement() {
+				hub.Publish(realtime.NewEvent(s, we))
+			}
+		case errors.Is(err, ingest.ErrDropped):
+			// the client is not slowed down, the queue logs the dropped records
+		default:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		tag.ApplyCors(w)
+		tag.ApplyCors(w, r)
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
@@ -0,0 +1,421 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/rs/zerolog"
+)
+
+// Config holds everything that can be changed between deployments. Values
+// are resolved in order of precedence: command line flags, then environment
+// variables, then the config file, then the defaults.
+type Config struct {
+	Env            string   `json:"env"`
+	Addr           string   `json:"addr"`
+	DB             DB       `json:"db"`
+	SessionTimeout Duration `json:"session_timeout"`
+	CorsOrigins    []string `json:"cors_origins"`
+	LogLevel       string   `json:"log_level"`
+	TrustedProxies []string `json:"trusted_proxies"`
+	Bots           Bots     `json:"bots"`
+	// GeoIPDB is the path to a MaxMind or DB-IP .mmdb file used to locate
+	// visitors, without it only CDN location headers are used.
+	GeoIPDB string `json:"geoip_db"`
+	// ReferrerSources is a file of extra referrer sources, in the format of
+	// the bundled list.
+	ReferrerSources string  `json:"referrer_sources"`
+	Revenue         Revenue `json:"revenue"`
+}
+
+type DB struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+type Bots struct {
+	// Filter is drop, flag or off.
+	Filter string `json:"filter"`
+	// CrawlerPatterns is a file of extra user agent patterns.
+	CrawlerPatterns string `json:"crawler_patterns"`
+	// BlockedNetworks are CIDRs whose traffic is treated as automated.
+	BlockedNetworks []string `json:"blocked_networks"`
+	// BlockedNetworkFiles are files of CIDRs, e.g. published datacenter ranges.
+	BlockedNetworkFiles []string `json:"blocked_network_files"`
+}
+
+type Revenue struct {
+	// Currency is the ISO 4217 code that revenue is reported in.
+	Currency string `json:"currency"`
+	// ExchangeRates is how much one unit of each other currency is worth in
+	// Currency, revenue in a currency without a rate is left out of totals.
+	ExchangeRates map[string]float64 `json:"exchange_rates"`
+}
+
+// Duration is a time.Duration read from strings such as "30m" in the config
+// file.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func Default() *Config {
+	return &Config{
+		Addr: ":3000",
+		DB: DB{
+			Driver: "sqlite",
+			DSN:    "./cmd/main/analytics.db",
+		},
+		SessionTimeout: Duration(30 * time.Minute),
+		CorsOrigins:    []string{"*"},
+		LogLevel:       "info",
+		TrustedProxies: []string{"127.0.0.0/8", "::1/128"},
+		Bots: Bots{
+			Filter: string(event.FilterDrop),
+		},
+		Revenue: Revenue{
+			Currency: "USD",
+		},
+	}
+}
+
+// Load builds the config from the command line arguments (without the
+// program name) and the environment.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("gotrack", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a JSON config file (env GOTRACK_CONFIG)")
+	env := fs.String("env", "", "environment name, dev enables debug features (env GO_ENV)")
+	addr := fs.String("addr", "", "address to listen on, e.g. :3000 (env GOTRACK_ADDR)")
+	driver := fs.String("db-driver", "", "database driver, sqlite or postgres (env GOTRACK_DB_DRIVER)")
+	dsn := fs.String("db-dsn", "", "sqlite database path or postgres connection string (env GOTRACK_DB_DSN)")
+	timeout := fs.Duration("session-timeout", 0, "inactivity after which a new visit starts (env GOTRACK_SESSION_TIMEOUT)")
+	origins := fs.String("cors-origins", "", "comma separated origins allowed to call the api, or * (env GOTRACK_CORS_ORIGINS)")
+	level := fs.String("log-level", "", "trace, debug, info, warn, error or disabled (env GOTRACK_LOG_LEVEL)")
+	proxies := fs.String("trusted-proxies", "", "comma separated IPs or CIDRs allowed to set X-Forwarded-For (env GOTRACK_TRUSTED_PROXIES)")
+	bots := fs.String("bot-filter", "", "drop, flag or off (env GOTRACK_BOT_FILTER)")
+	crawlers := fs.String("crawler-patterns", "", "file of extra crawler user agent patterns (env GOTRACK_CRAWLER_PATTERNS)")
+	blocked := fs.String("blocked-networks", "", "comma separated CIDRs treated as automated traffic (env GOTRACK_BLOCKED_NETWORKS)")
+	blockedFiles := fs.String("blocked-network-files", "", "comma separated files of CIDRs treated as automated traffic (env GOTRACK_BLOCKED_NETWORK_FILES)")
+	geoip := fs.String("geoip-db", "", "path to a MaxMind or DB-IP .mmdb file (env GOTRACK_GEOIP_DB)")
+	sources := fs.String("referrer-sources", "", "file of extra referrer sources (env GOTRACK_REFERRER_SOURCES)")
+	currency := fs.String("revenue-currency", "", "ISO 4217 currency that revenue is reported in (env GOTRACK_REVENUE_CURRENCY)")
+	var rates map[string]float64
+	fs.Func("exchange-rates", "comma separated rates to the revenue currency, e.g. EUR=1.08,GBP=1.27 (env GOTRACK_EXCHANGE_RATES)", func(s string) error {
+		r, err := parseRates(s)
+		rates = r
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	file := *path
+	if file == "" {
+		file = getenv("GOTRACK_CONFIG")
+	}
+	if file != "" {
+		if err := c.readFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.readEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "env":
+			c.Env = *env
+		case "addr":
+			c.Addr = *addr
+		case "db-driver":
+			c.DB.Driver = *driver
+		case "db-dsn":
+			c.DB.DSN = *dsn
+		case "session-timeout":
+			c.SessionTimeout = Duration(*timeout)
+		case "cors-origins":
+			c.CorsOrigins = splitList(*origins)
+		case "log-level":
+			c.LogLevel = *level
+		case "trusted-proxies":
+			c.TrustedProxies = splitList(*proxies)
+		case "bot-filter":
+			c.Bots.Filter = *bots
+		case "crawler-patterns":
+			c.Bots.CrawlerPatterns = *crawlers
+		case "blocked-networks":
+			c.Bots.BlockedNetworks = splitList(*blocked)
+		case "blocked-network-files":
+			c.Bots.BlockedNetworkFiles = splitList(*blockedFiles)
+		case "geoip-db":
+			c.GeoIPDB = *geoip
+		case "referrer-sources":
+			c.ReferrerSources = *sources
+		case "revenue-currency":
+			c.Revenue.Currency = *currency
+		case "exchange-rates":
+			c.Revenue.ExchangeRates = rates
+		}
+	})
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) readEnv(getenv func(string) string) error {
+	if v := getenv("GO_ENV"); v != "" {
+		c.Env = v
+	}
+	if v := getenv("GOTRACK_ADDR"); v != "" {
+		c.Addr = v
+	}
+	if v := getenv("GOTRACK_DB_DRIVER"); v != "" {
+		c.DB.Driver = v
+	}
+	if v := getenv("GOTRACK_DB_DSN"); v != "" {
+		c.DB.DSN = v
+	}
+	if v := getenv("GOTRACK_SESSION_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("GOTRACK_SESSION_TIMEOUT: %w", err)
+		}
+		c.SessionTimeout = Duration(d)
+	}
+	if v := getenv("GOTRACK_CORS_ORIGINS"); v != "" {
+		c.CorsOrigins = splitList(v)
+	}
+	if v := getenv("GOTRACK_LOG_LEVEL"); v != "" {
+		c.LogLevel = v
+	}
+	if v := getenv("GOTRACK_TRUSTED_PROXIES"); v != "" {
+		c.TrustedProxies = splitList(v)
+	}
+	if v := getenv("GOTRACK_BOT_FILTER"); v != "" {
+		c.Bots.Filter = v
+	}
+	if v := getenv("GOTRACK_CRAWLER_PATTERNS"); v != "" {
+		c.Bots.CrawlerPatterns = v
+	}
+	if v := getenv("GOTRACK_BLOCKED_NETWORKS"); v != "" {
+		c.Bots.BlockedNetworks = splitList(v)
+	}
+	if v := getenv("GOTRACK_BLOCKED_NETWORK_FILES"); v != "" {
+		c.Bots.BlockedNetworkFiles = splitList(v)
+	}
+	if v := getenv("GOTRACK_GEOIP_DB"); v != "" {
+		c.GeoIPDB = v
+	}
+	if v := getenv("GOTRACK_REFERRER_SOURCES"); v != "" {
+		c.ReferrerSources = v
+	}
+	if v := getenv("GOTRACK_REVENUE_CURRENCY"); v != "" {
+		c.Revenue.Currency = v
+	}
+	if v := getenv("GOTRACK_EXCHANGE_RATES"); v != "" {
+		rates, err := parseRates(v)
+		if err != nil {
+			return fmt.Errorf("GOTRACK_EXCHANGE_RATES: %w", err)
+		}
+		c.Revenue.ExchangeRates = rates
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q: %w", c.Addr, err))
+	}
+
+	switch c.DB.Driver {
+	case "sqlite", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("db driver %q: must be sqlite or postgres", c.DB.Driver))
+	}
+	if c.DB.DSN == "" {
+		errs = append(errs, errors.New("db dsn: must not be empty"))
+	}
+
+	if c.SessionTimeout < Duration(time.Minute) {
+		errs = append(errs, fmt.Errorf("session timeout %s: must be at least 1m", time.Duration(c.SessionTimeout)))
+	}
+
+	for _, o := range c.CorsOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors origin %q: must be * or scheme://host[:port]", o))
+		}
+	}
+
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil || c.LogLevel == "" {
+		errs = append(errs, fmt.Errorf("log level %q: must be trace, debug, info, warn, error or disabled", c.LogLevel))
+	}
+
+	if _, err := c.ProxyNets(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch event.FilterMode(c.Bots.Filter) {
+	case event.FilterDrop, event.FilterFlag, event.FilterOff:
+	default:
+		errs = append(errs, fmt.Errorf("bot filter %q: must be drop, flag or off", c.Bots.Filter))
+	}
+	if c.Bots.CrawlerPatterns != "" {
+		if _, err := os.Stat(c.Bots.CrawlerPatterns); err != nil {
+			errs = append(errs, fmt.Errorf("crawler patterns: %w", err))
+		}
+	}
+	if _, err := c.BlockedNets(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.GeoIPDB != "" {
+		if _, err := os.Stat(c.GeoIPDB); err != nil {
+			errs = append(errs, fmt.Errorf("geoip db: %w", err))
+		}
+	}
+	if c.ReferrerSources != "" {
+		if _, err := os.Stat(c.ReferrerSources); err != nil {
+			errs = append(errs, fmt.Errorf("referrer sources: %w", err))
+		}
+	}
+
+	if !event.IsCurrency(c.Revenue.Currency) {
+		errs = append(errs, fmt.Errorf("revenue currency %q: must be an ISO 4217 code", c.Revenue.Currency))
+	}
+	for code, rate := range c.Revenue.ExchangeRates {
+		if !event.IsCurrency(code) {
+			errs = append(errs, fmt.Errorf("exchange rate %q: must be an ISO 4217 code", code))
+		}
+		if rate <= 0 {
+			errs = append(errs, fmt.Errorf("exchange rate %s %v: must be above 0", code, rate))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Level returns the parsed log level.
+func (c *Config) Level() zerolog.Level {
+	l, err := zerolog.ParseLevel(c.LogLevel)
+	if err != nil {
+		return zerolog.InfoLevel
+	}
+	return l
+}
+
+// ProxyNets parses the trusted proxies, a single IP is treated as a /32 or
+// /128 network.
+func (c *Config) ProxyNets() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, p := range c.TrustedProxies {
+		n, err := event.ParseNetwork(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// BlockedNets parses the blocked networks and reads the blocked network files.
+func (c *Config) BlockedNets() ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, b := range c.Bots.BlockedNetworks {
+		n, err := event.ParseNetwork(b)
+		if err != nil {
+			return nil, fmt.Errorf("blocked network %q: %w", b, err)
+		}
+		nets = append(nets, n)
+	}
+	for _, f := range c.Bots.BlockedNetworkFiles {
+		n, err := event.LoadNetworks(f)
+		if err != nil {
+			return nil, fmt.Errorf("blocked network file %s: %w", f, err)
+		}
+		nets = append(nets, n...)
+	}
+	return nets, nil
+}
+
+func (c *Config) IsDev() bool {
+	return c.Env == "dev"
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseRates parses rates such as "EUR=1.08,GBP=1.27".
+func parseRates(s string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, r := range splitList(s) {
+		code, v, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("exchange rate %q: must be CODE=rate", r)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("exchange rate %q: %w", r, err)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gotrack.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	file := writeFile(t, `{
+		"addr": ":4000",
+		"db": {"driver": "postgres", "dsn": "postgres://localhost/gotrack"},
+		"session_timeout": "10m",
+		"cors_origins": ["https://file.example"],
+		"log_level": "warn",
+		"revenue": {"currency": "EUR", "exchange_rates": {"USD": 0.9}}
+	}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		// check returns the value under test and what it should be
+		check func(c *Config) (interface{}, interface{})
+	}{
+		{
+			name:  "defaults",
+			check: func(c *Config) (interface{}, interface{}) { return c, Default() },
+		},
+		{
+			name:  "file over defaults",
+			args:  []string{"-config", file},
+			check: func(c *Config) (interface{}, interface{}) { return c.Addr, ":4000" },
+		},
+		{
+			name: "file from the environment",
+			env:  map[string]string{"GOTRACK_CONFIG": file},
+			check: func(c *Config) (interface{}, interface{}) {
+				return c.DB, DB{Driver: "postgres", DSN: "postgres://localhost/gotrack"}
+			},
+		},
+		{
+			name:  "file keeps the defaults it does not set",
+			args:  []string{"-config", file},
+			check: func(c *Config) (interface{}, interface{}) { return c.TrustedProxies, Default().TrustedProxies },
+		},
+		{
+			name: "env over file",
+			args: []string{"-config", file},
+			env:  map[string]string{"GOTRACK_ADDR": ":5000", "GOTRACK_SESSION_TIMEOUT": "45m"},
+			check: func(c *Config) (interface{}, interface{}) {
+				return []interface{}{c.Addr, c.SessionTimeout}, []interface{}{":5000", Duration(45 * time.Minute)}
+			},
+		},
+		{
+			name:  "flag over env",
+			args:  []string{"-config", file, "-addr", ":6000"},
+			env:   map[string]string{"GOTRACK_ADDR": ":5000"},
+			check: func(c *Config) (interface{}, interface{}) { return c.Addr, ":6000" },
+		},
+		{
+			name: "lists",
+			args: []string{"-config", file, "-trusted-proxies", "10.0.0.0/8, 192.0.2.1"},
+			env:  map[string]string{"GOTRACK_CORS_ORIGINS": "https://a.example,https://b.example"},
+			check: func(c *Config) (interface{}, interface{}) {
+				return [][]string{c.CorsOrigins, c.TrustedProxies}, [][]string{{"https://a.example", "https://b.example"}, {"10.0.0.0/8", "192.0.2.1"}}
+			},
+		},
+		{
+			name: "exchange rates",
+			args: []string{"-config", file, "-exchange-rates", "usd=0.92, GBP=1.17"},
+			check: func(c *Config) (interface{}, interface{}) {
+				return c.Revenue.ExchangeRates, map[string]float64{"USD": 0.92, "GBP": 1.17}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.args, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := tt.check(c); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"unknown flag", []string{"-colour", "red"}, nil},
+		{"missing file", []string{"-config", filepath.Join(t.TempDir(), "missing.json")}, nil},
+		{"unknown file field", []string{"-config", writeFile(t, `{"colour": "red"}`)}, nil},
+		{"invalid env duration", nil, map[string]string{"GOTRACK_SESSION_TIMEOUT": "soon"}},
+		{"invalid env rates", nil, map[string]string{"GOTRACK_EXCHANGE_RATES": "EUR"}},
+		{"invalid flag rates", []string{"-exchange-rates", "EUR=much"}, nil},
+		{"invalid after merging", []string{"-db-driver", "mysql"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.args, func(key string) string { return tt.env[key] }); err == nil {
+				t.Error("Load did not fail")
+			}
+		})
+	}
+
+	if _, err := Load([]string{"-h"}, func(string) string { return "" }); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) error = %v, want %v", err, flag.ErrHelp)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		// want is part of the error, empty when the config is valid
+		want string
+	}{
+		{"default", func(c *Config) {}, ""},
+		{"host and port", func(c *Config) { c.Addr = "127.0.0.1:8080" }, ""},
+		{"addr without port", func(c *Config) { c.Addr = "3000" }, "addr"},
+		{"db driver", func(c *Config) { c.DB.Driver = "mysql" }, "db driver"},
+		{"db dsn", func(c *Config) { c.DB.DSN = "" }, "db dsn"},
+		{"session timeout", func(c *Config) { c.SessionTimeout = Duration(10 * time.Second) }, "session timeout"},
+		{"cors origin", func(c *Config) { c.CorsOrigins = []string{"https://example.com", "example.com"} }, "cors origin"},
+		{"cors origin with a path", func(c *Config) { c.CorsOrigins = []string{"https://example.com/app"} }, "cors origin"},
+		{"log level", func(c *Config) { c.LogLevel = "loud" }, "log level"},
+		{"empty log level", func(c *Config) { c.LogLevel = "" }, "log level"},
+		{"trusted proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/33"} }, "trusted proxy"},
+		{"bot filter", func(c *Config) { c.Bots.Filter = "block" }, "bot filter"},
+		{"crawler patterns", func(c *Config) { c.Bots.CrawlerPatterns = missing }, "crawler patterns"},
+		{"blocked network", func(c *Config) { c.Bots.BlockedNetworks = []string{"datacenter"} }, "blocked network"},
+		{"blocked network file", func(c *Config) { c.Bots.BlockedNetworkFiles = []string{missing} }, "blocked network file"},
+		{"geoip db", func(c *Config) { c.GeoIPDB = missing }, "geoip db"},
+		{"referrer sources", func(c *Config) { c.ReferrerSources = missing }, "referrer sources"},
+		{"currency", func(c *Config) { c.Revenue.Currency = "usd" }, "revenue currency"},
+		{"exchange rate currency", func(c *Config) { c.Revenue.ExchangeRates = map[string]float64{"XYZ": 1} }, "exchange rate"},
+		{"exchange rate", func(c *Config) { c.Revenue.ExchangeRates = map[string]float64{"EUR": 0} }, "exchange rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.change(c)
+			err := c.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() error = %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() error = %v, want one about %s", err, tt.want)
+			}
+		})
+	}
+
+	// every problem is reported at once
+	c := Default()
+	c.Addr = "3000"
+	c.Bots.Filter = "block"
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "addr") || !strings.Contains(err.Error(), "bot filter") {
+		t.Errorf("Validate() error = %v, want both problems", err)
+	}
+}
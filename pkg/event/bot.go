@@ -0,0 +1,140 @@
+package event
+
+import (
+	"bufio"
+	_ "embed"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/mileusna/useragent"
+)
+
+// FilterMode decides what happens to events sent by automated traffic.
+type FilterMode string
+
+const (
+	// FilterDrop counts the event as filtered and discards it.
+	FilterDrop FilterMode = "drop"
+	// FilterFlag stores the event with its reason, it is left out of every report.
+	FilterFlag FilterMode = "flag"
+	// FilterOff disables bot detection.
+	FilterOff FilterMode = "off"
+)
+
+const (
+	BotUserAgent  = "user_agent"
+	BotCrawler    = "crawler"
+	BotWebdriver  = "webdriver"
+	BotHeadless   = "headless"
+	BotDatacenter = "datacenter"
+)
+
+var BotFilter = FilterDrop
+
+// BlockedNetworks are address ranges, such as datacenters, whose traffic is
+// treated as automated.
+var BlockedNetworks []*net.IPNet
+
+//go:embed crawlers.txt
+var crawlersTxt string
+
+var crawlerPatterns = parsePatterns(crawlersTxt)
+
+// LoadCrawlerPatterns adds the patterns in the file at path to the bundled
+// crawler list.
+func LoadCrawlerPatterns(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	crawlerPatterns = append(crawlerPatterns, parsePatterns(string(b))...)
+	return nil
+}
+
+// LoadNetworks reads a file of CIDRs or single IPs, one per line.
+func LoadNetworks(path string) ([]*net.IPNet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nets []*net.IPNet
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n, err := ParseNetwork(line)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, sc.Err()
+}
+
+// ParseNetwork parses a CIDR, a single IP is treated as a /32 or /128 network.
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parsePatterns(s string) []string {
+	var patterns []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// DetectBot returns the reason the event looks automated, or an empty string
+// for a regular visitor.
+func DetectBot(e *Event, ua string, ip string) string {
+	if ua == "" || useragent.Parse(ua).Bot {
+		return BotUserAgent
+	}
+
+	lower := strings.ToLower(ua)
+	for _, p := range crawlerPatterns {
+		if strings.Contains(lower, p) {
+			return BotCrawler
+		}
+	}
+
+	if e.Webdriver {
+		return BotWebdriver
+	}
+	if e.Headless {
+		return BotHeadless
+	}
+
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, n := range BlockedNetworks {
+			if n.Contains(addr) {
+				return BotDatacenter
+			}
+		}
+	}
+
+	return ""
+}
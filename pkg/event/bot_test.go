@@ -0,0 +1,74 @@
+package event
+
+import "testing"
+
+func TestDetectBot(t *testing.T) {
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36"
+
+	blocked := BlockedNetworks
+	t.Cleanup(func() { BlockedNetworks = blocked })
+	BlockedNetworks = nil
+	for _, s := range []string{"203.0.113.0/24", "2001:db8::/32", "198.51.100.7"} {
+		n, err := ParseNetwork(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		BlockedNetworks = append(BlockedNetworks, n)
+	}
+
+	tests := []struct {
+		name string
+		ev   Event
+		ua   string
+		ip   string
+		want string
+	}{
+		{"visitor", Event{}, chrome, "192.0.2.1", ""},
+		{"no user agent", Event{}, "", "192.0.2.1", BotUserAgent},
+		{"known bot", Event{}, "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "192.0.2.1", BotUserAgent},
+		{"http library", Event{}, "python-requests/2.31.0", "192.0.2.1", BotCrawler},
+		{"crawler pattern is case insensitive", Event{}, "CURL/8.4.0", "192.0.2.1", BotCrawler},
+		{"webdriver", Event{Webdriver: true}, chrome, "192.0.2.1", BotWebdriver},
+		{"headless", Event{Headless: true}, chrome, "192.0.2.1", BotHeadless},
+		{"webdriver before headless", Event{Webdriver: true, Headless: true}, chrome, "192.0.2.1", BotWebdriver},
+		{"crawler before webdriver", Event{Webdriver: true}, "curl/8.4.0", "192.0.2.1", BotCrawler},
+		{"datacenter", Event{}, chrome, "203.0.113.50", BotDatacenter},
+		{"datacenter ipv6", Event{}, chrome, "2001:db8::1", BotDatacenter},
+		{"single blocked ip", Event{}, chrome, "198.51.100.7", BotDatacenter},
+		{"next to a blocked ip", Event{}, chrome, "198.51.100.8", ""},
+		{"no ip", Event{}, chrome, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectBot(&tt.ev, tt.ua, tt.ip); got != tt.want {
+				t.Errorf("DetectBot(%+v, %q, %q) = %q, want %q", tt.ev, tt.ua, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.0/24": "203.0.113.0/24",
+		"203.0.113.9/24": "203.0.113.0/24",
+		"198.51.100.7":   "198.51.100.7/32",
+		"2001:db8::/32":  "2001:db8::/32",
+		"2001:db8::1":    "2001:db8::1/128",
+	}
+	for in, want := range tests {
+		n, err := ParseNetwork(in)
+		if err != nil {
+			t.Errorf("ParseNetwork(%q) error = %v", in, err)
+			continue
+		}
+		if n.String() != want {
+			t.Errorf("ParseNetwork(%q) = %s, want %s", in, n, want)
+		}
+	}
+
+	for _, in := range []string{"", "example.com", "203.0.113.0/33", "203.0.113"} {
+		if n, err := ParseNetwork(in); err == nil {
+			t.Errorf("ParseNetwork(%q) = %s, want an error", in, n)
+		}
+	}
+}
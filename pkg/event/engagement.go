@@ -0,0 +1,46 @@
+package event
+
+import (
+	"errors"
+	"time"
+)
+
+// EngagementEvent is sent by the tag when a page is hidden or left, with the
+// time the page was visible and focused since the last one and how far it
+// was scrolled. It is not an interaction, so it never starts a visit and
+// does not count towards its events.
+const EngagementEvent = "engagement"
+
+// MaxEngagementTime is the most engaged time one engagement event may report,
+// longer times are cut to it.
+const MaxEngagementTime = 30 * time.Minute
+
+type Engagement struct {
+	Time time.Duration
+	// ScrollDepth is the furthest the page was scrolled, as a percentage of
+	// its height.
+	ScrollDepth int
+}
+
+// ParseEngagement reads the engaged milliseconds and scroll depth of an
+// engagement event.
+func ParseEngagement(ms int64, scrollDepth int) (*Engagement, error) {
+	if ms < 0 {
+		return nil, errors.New("engagement time cannot be negative")
+	}
+	if scrollDepth < 0 || scrollDepth > 100 {
+		return nil, errors.New("scroll depth must be between 0 and 100")
+	}
+
+	t := time.Duration(ms) * time.Millisecond
+	if t > MaxEngagementTime {
+		t = MaxEngagementTime
+	}
+	return &Engagement{Time: t, ScrollDepth: scrollDepth}, nil
+}
+
+// IsEngagement reports whether e is an engagement event rather than an
+// interaction.
+func (e *WEvent) IsEngagement() bool {
+	return e.EventName == EngagementEvent
+}
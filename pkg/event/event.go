@@ -4,19 +4,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-
-	"github.com/danecwalker/gotrack/pkg/utils"
+	"net/url"
+	"strings"
 )
 
-const SessionTimeout = 30 * 60
+// SessionTimeout is the number of seconds of inactivity after which a new
+// visit starts.
+var SessionTimeout = 30 * 60
 
 type Event struct {
+	Domain       string                 `json:"d"`
 	EventName    string                 `json:"n"`
 	Url          string                 `json:"u"`
 	Referrer     string                 `json:"r"`
 	Props        map[string]interface{} `json:"p"`
 	ViewportSize string                 `json:"v"`
 	Revenue      map[string]interface{} `json:"$"`
+	Webdriver    bool                   `json:"w"`
+	Headless     bool                   `json:"h"`
+	// VisitorID is the random id the tag keeps for the visitor when it is
+	// opted in to recognising returning visitors.
+	VisitorID string `json:"i"`
+	// EngagementTime is the engaged milliseconds and ScrollDepth the scroll
+	// percentage of an engagement event.
+	EngagementTime int64 `json:"e"`
+	ScrollDepth    int   `json:"sd"`
+	// UrlOverride is the url the page wants to be tracked as instead of Url,
+	// which may be relative to it.
+	UrlOverride string `json:"o"`
+	// HashRouting keeps the fragment of the url, for pages that route on it.
+	HashRouting bool `json:"hr"`
 }
 
 func (e *Event) Parse(r *http.Request) (*Session, *WEvent, error) {
@@ -24,16 +41,42 @@ func (e *Event) Parse(r *http.Request) (*Session, *WEvent, error) {
 		return nil, nil, err
 	}
 
-	s := NewSession(r)
+	domain := e.Domain
+	if domain == "" {
+		// fall back to the host of the page the event was sent from
+		location, err := url.Parse(strings.TrimSpace(e.Url))
+		if err != nil {
+			return nil, nil, err
+		}
+		domain = location.Hostname()
+	}
+	domain = NormalizeDomain(domain)
+	if domain == "" {
+		return nil, nil, fmt.Errorf("missing domain")
+	}
+
+	ip := getIP(r)
+	s := NewSession(r, ip, domain)
 	s.ParseViewportSize(e.ViewportSize)
 	s.ParseLanguage(r.Header.Get("Accept-Language"))
+	s.ParseLocation(r, ip)
 	s.ParseUA(r.Header.Get("User-Agent"), r.Header.Get("Sec-CH-UA-Platform"), r.Header.Get("Sec-CH-UA"))
+	s.ParseVisitorID(e.VisitorID)
 
-	ev := NewWEvent(s.SessionID)
-	ev.Parse(e)
-
-	fmt.Println(utils.PrettyJson(ev))
-	fmt.Println(utils.PrettyJson(s))
+	ev := NewWEvent(s.Domain, s.SessionID)
+	if err := ev.Parse(e); err != nil {
+		return nil, nil, err
+	}
+	if BotFilter != FilterOff {
+		ev.BotReason = DetectBot(e, r.Header.Get("User-Agent"), ip)
+	}
 
 	return s, ev, nil
 }
+
+// NormalizeDomain lowercases a domain and strips any leading "www." so that
+// www.example.com and example.com are tracked as the same site.
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	return strings.TrimPrefix(domain, "www.")
+}
@@ -0,0 +1,57 @@
+package event
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The tag sends these events for clicks on links to other sites, clicks on
+// links to files and pages that were not found. Each is about the url in its
+// "url" prop.
+const (
+	OutboundLinkEvent = "outboundlink"
+	FileDownloadEvent = "filedownload"
+	NotFoundEvent     = "404"
+)
+
+// IsLinkEvent reports whether name is one of the events with a url prop.
+func IsLinkEvent(name string) bool {
+	return name == OutboundLinkEvent || name == FileDownloadEvent || name == NotFoundEvent
+}
+
+// parseLink checks the url prop of a link event, a 404 without one is about
+// the page it was sent from. Outbound and download urls lose their fragment
+// and 404 urls are kept like pages, see pageURL. The referrer of a
+// 404 is kept in its "referrer" prop even when it is a page of the site, as
+// that is where the broken link is.
+func (e *WEvent) parseLink(ev *Event) error {
+	if e.Props == nil {
+		e.Props = make(map[string]interface{})
+	}
+
+	raw, ok := e.Props["url"]
+	if !ok && e.EventName == NotFoundEvent {
+		raw = e.Url
+	}
+	s, _ := raw.(string)
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s event needs an absolute url prop", e.EventName)
+	}
+
+	if e.EventName == NotFoundEvent {
+		e.Props["url"] = pageURL(u, ev.HashRouting)
+
+		if _, ok := e.Props["referrer"]; !ok && ev.Referrer != "" {
+			if refer, err := url.Parse(ev.Referrer); err == nil && refer.Host != "" {
+				e.Props["referrer"] = refer.Scheme + "://" + refer.Host + refer.Path
+			}
+		}
+		return nil
+	}
+
+	u.Fragment = ""
+	e.Props["url"] = u.String()
+	return nil
+}
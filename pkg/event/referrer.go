@@ -0,0 +1,110 @@
+package event
+
+import (
+	_ "embed"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type referrerSource struct {
+	name  string
+	hosts []string
+}
+
+//go:embed referrers.txt
+var referrersTxt string
+
+var referrerSources = parseReferrerSources(referrersTxt)
+
+// LoadReferrerSources reads extra sources from the file at path, they are
+// checked before the bundled ones so they can also override them.
+func LoadReferrerSources(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	referrerSources = append(parseReferrerSources(string(b)), referrerSources...)
+	return nil
+}
+
+func parseReferrerSources(s string) []referrerSource {
+	var sources []referrerSource
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hosts, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		sources = append(sources, referrerSource{
+			name:  strings.TrimSpace(name),
+			hosts: strings.Fields(strings.ToLower(hosts)),
+		})
+	}
+	return sources
+}
+
+// NormalizeReferrer returns the referrer and the name of its source, e.g.
+// "Google" for https://www.google.co.uk/. Referrers from the site itself are
+// not referrals, so both are empty for them. Unknown referrers are named
+// after their host.
+func NormalizeReferrer(referrer string, domain string) (string, string) {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return "", ""
+	}
+
+	host := referrerHost(referrer)
+	if host == "" {
+		// a ?ref= value such as "newsletter"
+		return referrer, referrer
+	}
+
+	if domain != "" && NormalizeDomain(host) == NormalizeDomain(domain) {
+		return "", ""
+	}
+
+	for _, s := range referrerSources {
+		for _, h := range s.hosts {
+			if matchHost(host, h) {
+				return referrer, s.name
+			}
+		}
+	}
+
+	return referrer, NormalizeDomain(host)
+}
+
+func referrerHost(referrer string) string {
+	if !strings.Contains(referrer, "://") {
+		if !strings.Contains(referrer, ".") {
+			return ""
+		}
+		referrer = "//" + referrer
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func matchHost(host string, pattern string) bool {
+	if name, ok := strings.CutSuffix(pattern, ".*"); ok {
+		// the name followed by a top level domain of at most two labels,
+		// e.g. google.com or google.co.uk
+		labels := strings.Split(host, ".")
+		for i, l := range labels {
+			if l == name && i < len(labels)-1 && len(labels)-i-1 <= 2 {
+				return true
+			}
+		}
+		return false
+	}
+
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
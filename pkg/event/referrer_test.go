@@ -0,0 +1,62 @@
+package event
+
+import "testing"
+
+func TestNormalizeReferrer(t *testing.T) {
+	tests := []struct {
+		referrer string
+		domain   string
+		want     string
+		source   string
+	}{
+		{"", "example.com", "", ""},
+		{"  ", "example.com", "", ""},
+		{" https://www.google.co.uk/ ", "example.com", "https://www.google.co.uk/", "Google"},
+		{"https://www.google.com/search", "example.com", "https://www.google.com/search", "Google"},
+		// more specific hosts are listed first
+		{"https://mail.google.com/", "example.com", "https://mail.google.com/", "Gmail"},
+		{"https://news.google.com/", "example.com", "https://news.google.com/", "Google News"},
+		{"android-app://com.google.android.gm/", "example.com", "android-app://com.google.android.gm/", "Gmail"},
+		{"https://search.yahoo.co.jp/", "example.com", "https://search.yahoo.co.jp/", "Yahoo!"},
+		{"https://www.bing.com/", "example.com", "https://www.bing.com/", "Bing"},
+		{"https://t.co/abc", "example.com", "https://t.co/abc", "Twitter"},
+		{"news.ycombinator.com", "example.com", "news.ycombinator.com", "Hacker News"},
+		{"https://notgoogle.com/", "example.com", "https://notgoogle.com/", "notgoogle.com"},
+		{"https://WWW.Unknown.ORG/path", "example.com", "https://WWW.Unknown.ORG/path", "unknown.org"},
+		// a ?ref= value
+		{"newsletter", "example.com", "newsletter", "newsletter"},
+		// the site itself is not a referral, but its other subdomains are
+		{"https://www.example.com/blog", "example.com", "", ""},
+		{"https://example.com/", "www.Example.com", "", ""},
+		{"https://blog.example.com/", "example.com", "https://blog.example.com/", "blog.example.com"},
+		{"https://example.com/", "", "https://example.com/", "example.com"},
+	}
+	for _, tt := range tests {
+		got, source := NormalizeReferrer(tt.referrer, tt.domain)
+		if got != tt.want || source != tt.source {
+			t.Errorf("NormalizeReferrer(%q, %q) = %q, %q, want %q, %q", tt.referrer, tt.domain, got, source, tt.want, tt.source)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		pattern string
+		want    bool
+	}{
+		{"bing.com", "bing.com", true},
+		{"www.bing.com", "bing.com", true},
+		{"notbing.com", "bing.com", false},
+		{"google.com", "google.*", true},
+		{"www.google.co.uk", "google.*", true},
+		{"google", "google.*", false},
+		{"google.a.b.c", "google.*", false},
+		{"mygoogle.com", "google.*", false},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.host, tt.pattern); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tt.host, tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MicrosPerUnit is the number of micros in one unit of a currency, amounts are
+// kept in micros so decimal amounts add up exactly.
+const MicrosPerUnit = 1_000_000
+
+// Money is the revenue of an event parsed from its "$" payload, which the tag
+// fills from the ga-revenue-amount and ga-revenue-currency attributes.
+type Money struct {
+	Micros int64
+	// Currency is the ISO 4217 code, e.g. "USD".
+	Currency string
+}
+
+var amountPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,6})?$`)
+
+// ParseMoney reads the "amount" and "currency" of a revenue payload. The
+// amount is a decimal string or a number with at most six decimal places.
+func ParseMoney(revenue map[string]interface{}) (*Money, error) {
+	var amount string
+	switch v := revenue["amount"].(type) {
+	case string:
+		amount = strings.TrimSpace(v)
+	case float64:
+		amount = strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return nil, errors.New("revenue amount is missing")
+	default:
+		return nil, fmt.Errorf("revenue amount %v is not a number", v)
+	}
+	if !amountPattern.MatchString(amount) {
+		return nil, fmt.Errorf("revenue amount %q is not a decimal number", amount)
+	}
+
+	currency, _ := revenue["currency"].(string)
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !IsCurrency(currency) {
+		return nil, fmt.Errorf("revenue currency %q is not an ISO 4217 code", currency)
+	}
+
+	micros, err := parseMicros(amount)
+	if err != nil {
+		return nil, fmt.Errorf("revenue amount %q: %w", amount, err)
+	}
+	return &Money{Micros: micros, Currency: currency}, nil
+}
+
+// parseMicros converts a decimal matching amountPattern to micros without
+// going through a float.
+func parseMicros(amount string) (int64, error) {
+	neg := strings.HasPrefix(amount, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/MicrosPerUnit-1 {
+		return 0, errors.New("out of range")
+	}
+	micros, _ := strconv.ParseInt((frac + "000000")[:6], 10, 64)
+
+	micros += units * MicrosPerUnit
+	if neg {
+		micros = -micros
+	}
+	return micros, nil
+}
+
+// currencies are the active ISO 4217 codes.
+var currencies = make(map[string]bool)
+
+func init() {
+	for _, c := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+		BOB BRL BSD BTN BWP BYN BZD CAD CDF CHF CLF CLP CNY COP CRC CUP CVE CZK
+		DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD
+		HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW
+		KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU
+		MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR
+		PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP
+		STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS
+		VES VND VUV WST XAF XCD XCG XOF XPF YER ZAR ZMW ZWG
+	`) {
+		currencies[c] = true
+	}
+}
+
+// IsCurrency reports whether code is an active ISO 4217 currency code.
+func IsCurrency(code string) bool {
+	return currencies[code]
+}
@@ -0,0 +1,79 @@
+package event
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name    string
+		revenue map[string]interface{}
+		want    Money
+	}{
+		{"decimal string", map[string]interface{}{"amount": "19.99", "currency": "USD"}, Money{19_990_000, "USD"}},
+		{"number", map[string]interface{}{"amount": 19.99, "currency": "USD"}, Money{19_990_000, "USD"}},
+		{"whole number", map[string]interface{}{"amount": float64(42), "currency": "NZD"}, Money{42_000_000, "NZD"}},
+		{"spaces and case", map[string]interface{}{"amount": " 5 ", "currency": " eur "}, Money{5_000_000, "EUR"}},
+		{"refund", map[string]interface{}{"amount": "-3.5", "currency": "GBP"}, Money{-3_500_000, "GBP"}},
+		{"one micro", map[string]interface{}{"amount": "0.000001", "currency": "JPY"}, Money{1, "JPY"}},
+		{"largest", map[string]interface{}{"amount": "9223372036853", "currency": "USD"}, Money{9_223_372_036_853_000_000, "USD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMoney(tt.revenue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseMoney(%v) = %+v, want %+v", tt.revenue, *got, tt.want)
+			}
+		})
+	}
+
+	for _, revenue := range []map[string]interface{}{
+		{"currency": "USD"},
+		{"amount": true, "currency": "USD"},
+		{"amount": "", "currency": "USD"},
+		{"amount": "1,000", "currency": "USD"},
+		{"amount": "1e3", "currency": "USD"},
+		{"amount": "1.2345678", "currency": "USD"},
+		{"amount": "9223372036854", "currency": "USD"},
+		{"amount": 1e21, "currency": "USD"},
+		{"amount": "10"},
+		{"amount": "10", "currency": "XYZ"},
+		{"amount": "10", "currency": "US Dollar"},
+	} {
+		if m, err := ParseMoney(revenue); err == nil {
+			t.Errorf("ParseMoney(%v) = %+v, want an error", revenue, *m)
+		}
+	}
+}
+
+func TestParseRevenue(t *testing.T) {
+	tests := []struct {
+		name    string
+		revenue map[string]interface{}
+		want    *Money
+	}{
+		{"none", nil, nil},
+		{"valid", map[string]interface{}{"amount": "10", "currency": "USD"}, &Money{10_000_000, "USD"}},
+		{"bad amount", map[string]interface{}{"amount": "ten", "currency": "USD"}, nil},
+		{"bad currency", map[string]interface{}{"amount": "10", "currency": "XYZ"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := NewWEvent("example.com", "s1")
+			err := ev.Parse(&Event{EventName: "purchase", Url: "https://example.com/checkout", Revenue: tt.revenue})
+			if err != nil {
+				t.Fatalf("Parse error = %v, want the event kept", err)
+			}
+			if ev.EventName != "purchase" || ev.Url != "https://example.com/checkout" {
+				t.Errorf("event = %s %s, want purchase https://example.com/checkout", ev.EventName, ev.Url)
+			}
+			switch {
+			case tt.want == nil && (ev.Money != nil || len(ev.Revenue) > 0):
+				t.Errorf("revenue = %v, want none", ev.Revenue)
+			case tt.want != nil && (ev.Money == nil || *ev.Money != *tt.want):
+				t.Errorf("Money = %+v, want %+v", ev.Money, *tt.want)
+			}
+		})
+	}
+}
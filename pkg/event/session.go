@@ -1,13 +1,18 @@
 package event
 
 import (
-	"crypto/sha1"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/danecwalker/gotrack/pkg/geo"
 	"github.com/mileusna/useragent"
 )
 
@@ -24,27 +29,76 @@ const (
 var BreakPoints = []int{640, 768, 1024, 1280, 1536}
 
 type Session struct {
-	SessionID  string
+	SessionID string
+	// PreviousID is the id the visitor had under the previous salt, it is only
+	// set shortly after the salt rotated so that a visit can carry on.
+	PreviousID string
+	Domain     string
 	Language   string
 	Country    string
+	Region     string
+	City       string
 	Browser    string
-	Os         string
-	ScreenType ScreenType
+	// BrowserVersion is the major version, e.g. "120".
+	BrowserVersion string
+	Os             string
+	OsVersion      string
+	ScreenType     ScreenType
+	// VisitorID is the hash of the id the tag sent for the visitor, it lasts
+	// across days but is only kept for sites that recognise returning
+	// visitors.
+	VisitorID string
+	CreatedAt time.Time
 }
 
-func NewSession(r *http.Request) *Session {
-	ip := getIP(r)
-	sha := sha1.New()
-	sha.Write([]byte(ip))
-	sha.Write([]byte(r.Header.Get("User-Agent")))
-	sha.Write([]byte(fmt.Sprintf("%d", time.Now().Unix()/60/60/24)))
+// Salter hashes visitor details with secret salts, so a session id cannot be
+// traced back to an address. The salts are kept in the same database as the
+// data only while they are in use, and are overwritten once they rotate out.
+type Salter interface {
+	// Sum returns the keyed hash of b under the current salt, and under the
+	// previous salt while it is still kept, otherwise an empty string.
+	Sum(b []byte) (current string, previous string)
+}
+
+// Salts is replaced on startup by salts that are shared through the store and
+// rotated daily. The default is a random salt that lives as long as the
+// process.
+var Salts Salter = newStaticSalt()
+
+type staticSalt []byte
+
+func newStaticSalt() staticSalt {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
+func (s staticSalt) Sum(b []byte) (string, string) {
+	return KeyedHash(s, b), ""
+}
+
+// KeyedHash returns the hex encoded HMAC-SHA256 of b keyed with salt.
+func KeyedHash(salt []byte, b []byte) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSession(r *http.Request, ip string, domain string) *Session {
+	// the fields are separated so that different combinations cannot collide
+	visitor := []byte(domain + "\x00" + ip + "\x00" + r.Header.Get("User-Agent"))
+	current, previous := Salts.Sum(visitor)
 	return &Session{
-		SessionID: fmt.Sprintf("%x", sha.Sum(nil)),
+		SessionID:  current,
+		PreviousID: previous,
+		Domain:     domain,
+		CreatedAt:  time.Now().UTC(),
 	}
 }
 
 func (s *Session) ParseUA(ua string, platform string, browser string) {
-	fmt.Println(ua)
 	if ua != "" {
 		agent := useragent.Parse(ua)
 		if platform != "" {
@@ -53,7 +107,13 @@ func (s *Session) ParseUA(ua string, platform string, browser string) {
 			s.Os = agent.OS
 		}
 
+		// the user agent only has the os version of the os it names
+		if s.Os == agent.OS {
+			s.OsVersion = agent.OSVersion
+		}
+
 		s.Browser = agent.Name
+		s.BrowserVersion = majorVersion(agent.Version)
 
 		browsers := strings.Split(browser, ", ")
 		re := regexp.MustCompile(`^Not.A Brand$`)
@@ -63,12 +123,31 @@ func (s *Session) ParseUA(ua string, platform string, browser string) {
 				agentName := strings.Trim(agent[0], "\"")
 				if agentName != "" && !re.MatchString(agentName) {
 					s.Browser = agentName
+					// the brand version is sent as v="120"
+					s.BrowserVersion = majorVersion(strings.Trim(strings.TrimPrefix(strings.TrimSpace(agent[1]), "v="), "\""))
 				}
 			}
 		}
 	}
 }
 
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+var visitorIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// ParseVisitorID sets VisitorID from the id sent by the tag. The id is hashed
+// with the domain so it cannot be matched across sites, and one that is not a
+// random token is ignored.
+func (s *Session) ParseVisitorID(id string) {
+	if !visitorIDPattern.MatchString(id) {
+		return
+	}
+	s.VisitorID = KeyedHash([]byte(s.Domain), []byte(id))
+}
+
 func (s *Session) ParseViewportSize(size string) {
 	if size == "" {
 		s.ScreenType = Desktop
@@ -98,16 +177,29 @@ func (s *Session) ParseViewportSize(size string) {
 
 func (s *Session) ParseLanguage(al string) {
 	if al != "" {
-		lang, country := parseAcceptLanguage(al)
+		lang, _ := parseAcceptLanguage(al)
 		if lang != "" {
 			s.Language = lang
 		}
-		if country != "" {
-			s.Country = country
-		}
 	}
 }
 
+// GeoIP is the database visitors are located with, when it is nil only the
+// location reported by a trusted CDN is used.
+var GeoIP *geo.DB
+
+// ParseLocation locates the visitor by ip. The ip itself is never stored.
+func (s *Session) ParseLocation(r *http.Request, ip string) {
+	loc := GeoIP.Lookup(ip)
+	if loc.Country == "" && isTrustedProxy(remoteIP(r)) {
+		loc = geo.FromHeaders(r.Header)
+	}
+
+	s.Country = loc.Country
+	s.Region = loc.Region
+	s.City = loc.City
+}
+
 func parseAcceptLanguage(al string) (string, string) {
 	for _, l := range strings.Split(al, ",") {
 		if strings.Contains(l, ";") {
@@ -145,27 +237,54 @@ func parseLanguage(l string) (string, string) {
 	return lang, country
 }
 
+// TrustedProxies are the networks allowed to report the client address in
+// the X-Real-IP and X-Forwarded-For headers.
+var TrustedProxies []*net.IPNet
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
 func getIP(r *http.Request) string {
-	// Get IP from X-REAL-IP or X-FORWARDED-FOR headers if present and not localhost or fallback to RemoteAddr otherwise
-	ip := r.Header.Get("X-REAL-IP")
-	if ip == "" {
-		ip = r.Header.Get("X-FORWARDED-FOR")
+	ip := remoteIP(r)
 
-		if ip == "" {
-			ip = r.RemoteAddr
+	// only trust the forwarding headers when the request came from one of our proxies
+	if isTrustedProxy(ip) {
+		if real := strings.TrimSpace(r.Header.Get("X-REAL-IP")); real != "" {
+			ip = real
+		} else if fwd := r.Header.Get("X-FORWARDED-FOR"); fwd != "" {
+			// walk the chain from the right and take the first address that was
+			// not added by a trusted proxy
+			hops := strings.Split(fwd, ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				ip = strings.TrimSpace(hops[i])
+				if !isTrustedProxy(ip) {
+					break
+				}
+			}
 		}
 	}
 
-	// check if localhost or [::1] and replace with 127.0.0.1
-	re := regexp.MustCompile(`^(localhost|\[::1\])(.+)$`)
-	if re.MatchString(ip) {
-		ip = re.ReplaceAllString(ip, "127.0.0.1")
+	// replace localhost or [::1] with 127.0.0.1
+	if ip == "localhost" || ip == "::1" {
+		ip = "127.0.0.1"
 	}
-	re = regexp.MustCompile(`^(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})(:.+)$`)
-	if re.MatchString(ip) {
-		ip = re.ReplaceAllString(ip, "$1")
-	}
-	fmt.Println(ip)
 
 	return ip
 }
+
+func isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range TrustedProxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,12 @@
 package event
 
 import (
+	"errors"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 type UTM struct {
@@ -14,27 +18,56 @@ type UTM struct {
 }
 
 type WEvent struct {
+	Domain    string
 	SessionID string
 	EventName string
 	Url       string
 	Referrer  string
-	Props     map[string]interface{}
-	Revenue   map[string]interface{}
-	UTM       *UTM
+	// ReferrerSource is the name of the site the visitor came from, e.g. "Google".
+	ReferrerSource string
+	Props          map[string]interface{}
+	Revenue        map[string]interface{}
+	// Money is the parsed Revenue, nil when the event has none.
+	Money *Money
+	// Engagement is set for engagement events only.
+	Engagement *Engagement
+	UTM        *UTM
+	// BotReason is set when the event was sent by automated traffic.
+	BotReason string
+	CreatedAt time.Time
 }
 
-func NewWEvent(session_id string) *WEvent {
+func NewWEvent(domain string, session_id string) *WEvent {
 	return &WEvent{
+		Domain:    domain,
 		SessionID: session_id,
 		Props:     make(map[string]interface{}),
 		Revenue:   make(map[string]interface{}),
+		CreatedAt: time.Now().UTC(),
 	}
 }
 
 func (e *WEvent) Parse(ev *Event) error {
 	e.EventName = strings.TrimSpace(ev.EventName)
 	e.Props = ev.Props
-	e.Revenue = ev.Revenue
+	if len(ev.Revenue) > 0 {
+		// a malformed revenue loses the revenue only, the event is still
+		// tracked
+		money, err := ParseMoney(ev.Revenue)
+		if err != nil {
+			log.Warn().Err(err).Str("domain", e.Domain).Str("event", e.EventName).Msg("dropping revenue")
+		} else {
+			e.Revenue = ev.Revenue
+			e.Money = money
+		}
+	}
+	if e.IsEngagement() {
+		engagement, err := ParseEngagement(ev.EngagementTime, ev.ScrollDepth)
+		if err != nil {
+			return err
+		}
+		e.Engagement = engagement
+	}
 
 	location, err := url.Parse(strings.TrimSpace(ev.Url))
 	if err != nil {
@@ -77,7 +110,36 @@ func (e *WEvent) Parse(ev *Event) error {
 		e.Referrer = refer.Scheme + "://" + refer.Host + refer.Path
 	}
 
-	e.Url = location.Scheme + "://" + location.Host + location.Path
+	page := location
+	if override := strings.TrimSpace(ev.UrlOverride); override != "" {
+		o, err := url.Parse(override)
+		if err != nil {
+			return err
+		}
+		page = location.ResolveReference(o)
+		if page.Scheme == "" || page.Host == "" {
+			return errors.New("url override must be absolute or relative to the page url")
+		}
+	}
+
+	e.Url = pageURL(page, ev.HashRouting)
+	e.Referrer, e.ReferrerSource = NormalizeReferrer(e.Referrer, e.Domain)
+
+	if IsLinkEvent(e.EventName) {
+		if err := e.parseLink(ev); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+// pageURL is how the url of a page is kept, without its query and, unless
+// the page routes on it, its fragment.
+func pageURL(u *url.URL, hash bool) string {
+	page := u.Scheme + "://" + u.Host + u.Path
+	if hash && u.Fragment != "" {
+		page += "#" + u.EscapedFragment()
+	}
+	return page
+}
@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where a visitor is, any field may be empty when it is unknown.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code, e.g. "NZ".
+	Country string
+	// Region is the English name of the first level subdivision, e.g. "Auckland".
+	Region string
+	City   string
+}
+
+// record holds the fields shared by the MaxMind GeoIP2/GeoLite2 and DB-IP
+// country and city databases.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// DB looks up addresses in a local .mmdb database.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: reader}, nil
+}
+
+// Lookup returns the location of ip, or an empty location when the address
+// is invalid or not in the database.
+func (db *DB) Lookup(ip string) Location {
+	addr := net.ParseIP(ip)
+	if db == nil || addr == nil {
+		return Location{}
+	}
+
+	var r record
+	if err := db.reader.Lookup(addr, &r); err != nil {
+		return Location{}
+	}
+
+	loc := Location{
+		Country: r.Country.ISOCode,
+		City:    r.City.Names["en"],
+	}
+	if len(r.Subdivisions) > 0 {
+		loc.Region = r.Subdivisions[0].Names["en"]
+	}
+	return loc
+}
+
+func (db *DB) Close() error {
+	return db.reader.Close()
+}
+
+// headers are the location headers set by CDNs, in order of preference.
+var headers = []struct {
+	country string
+	region  string
+	city    string
+}{
+	{"CF-IPCountry", "CF-Region", "CF-IPCity"},
+	{"CloudFront-Viewer-Country", "CloudFront-Viewer-Country-Region-Name", "CloudFront-Viewer-City"},
+	{"X-Vercel-IP-Country", "", "X-Vercel-IP-City"},
+	{"Fastly-Geo-Country-Code", "", "Fastly-Geo-City"},
+}
+
+// FromHeaders returns the location a CDN reported for the request.
+func FromHeaders(h http.Header) Location {
+	for _, names := range headers {
+		country := strings.ToUpper(strings.TrimSpace(h.Get(names.country)))
+		// XX is unknown and T1 is Tor for Cloudflare
+		if len(country) != 2 || country == "XX" || country == "T1" {
+			continue
+		}
+
+		loc := Location{Country: country}
+		if names.region != "" {
+			loc.Region = strings.TrimSpace(h.Get(names.region))
+		}
+		if names.city != "" {
+			// some CDNs url encode the city name
+			city := strings.TrimSpace(h.Get(names.city))
+			if c, err := url.QueryUnescape(city); err == nil {
+				city = c
+			}
+			loc.City = city
+		}
+		return loc
+	}
+	return Location{}
+}
@@ -0,0 +1,144 @@
+package geo
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeDB writes a city database with a few networks to a temporary file.
+func writeDB(t *testing.T) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "GeoIP2-City",
+		IPVersion:    6,
+		RecordSize:   28,
+		// the networks are the ones set aside for documentation
+		IncludeReservedNetworks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(en string) mmdbtype.Map {
+		return mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(en), "de": mmdbtype.String(en + "-de")}}
+	}
+	networks := []struct {
+		cidr   string
+		record mmdbtype.Map
+	}{
+		{"203.0.113.0/24", mmdbtype.Map{
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String("NZ")},
+			"subdivisions": mmdbtype.Slice{names("Auckland")},
+			"city":         names("Auckland"),
+		}},
+		// a country database has no subdivisions or city
+		{"198.51.100.0/24", mmdbtype.Map{
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		}},
+		{"2001:db8::/32", mmdbtype.Map{
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String("DE")},
+			"subdivisions": mmdbtype.Slice{names("Berlin"), names("Mitte")},
+			"city":         names("Berlin"),
+		}},
+	}
+	for _, n := range networks {
+		_, network, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Insert(network, n.record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	db, err := Open(writeDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{"203.0.113.7", Location{Country: "NZ", Region: "Auckland", City: "Auckland"}},
+		{"198.51.100.7", Location{Country: "GB"}},
+		// the first subdivision is the region
+		{"2001:db8::1", Location{Country: "DE", Region: "Berlin", City: "Berlin"}},
+		{"192.0.2.1", Location{}},
+		{"not an ip", Location{}},
+		{"", Location{}},
+	}
+	for _, tt := range tests {
+		if got := db.Lookup(tt.ip); got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestLookupWithoutDB(t *testing.T) {
+	var db *DB
+	if got := db.Lookup("203.0.113.7"); got != (Location{}) {
+		t.Errorf("Lookup without a database = %+v, want an empty location", got)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open of an invalid database did not fail")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Open of a missing database did not fail")
+	}
+}
+
+func TestFromHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Location
+	}{
+		{"none", nil, Location{}},
+		{"cloudflare", map[string]string{"CF-IPCountry": "nz", "CF-Region": "Auckland", "CF-IPCity": "Auckland"}, Location{"NZ", "Auckland", "Auckland"}},
+		{"cloudflare unknown", map[string]string{"CF-IPCountry": "XX"}, Location{}},
+		{"cloudflare tor", map[string]string{"CF-IPCountry": "T1"}, Location{}},
+		{"cloudfront", map[string]string{"CloudFront-Viewer-Country": "DE", "CloudFront-Viewer-Country-Region-Name": "Berlin", "CloudFront-Viewer-City": "Berlin"}, Location{"DE", "Berlin", "Berlin"}},
+		{"url encoded city", map[string]string{"X-Vercel-IP-Country": "US", "X-Vercel-IP-City": "San%20Francisco"}, Location{Country: "US", City: "San Francisco"}},
+		{"fastly", map[string]string{"Fastly-Geo-Country-Code": "GB", "Fastly-Geo-City": "London"}, Location{Country: "GB", City: "London"}},
+		{"first cdn wins", map[string]string{"CF-IPCountry": "NZ", "X-Vercel-IP-Country": "US"}, Location{Country: "NZ"}},
+		{"invalid country", map[string]string{"CF-IPCountry": "NZL", "Fastly-Geo-Country-Code": "AU"}, Location{Country: "AU"}},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+		if got := FromHeaders(h); got != tt.want {
+			t.Errorf("%s: FromHeaders = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
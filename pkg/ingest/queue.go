@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrQueueFull   = errors.New("ingest queue is full")
+	ErrQueueClosed = errors.New("ingest queue is closed")
+	// ErrDropped is returned under the Drop policy for a record that was
+	// discarded, which the client is not told about.
+	ErrDropped = errors.New("ingest queue is full, record dropped")
+)
+
+// Policy decides what happens to an event when the queue is full.
+type Policy int
+
+const (
+	// Drop accepts the event but discards it, so clients are never slowed down.
+	// The writer logs how many records were dropped.
+	Drop Policy = iota
+	// Reject refuses the event so the handler can answer with 503.
+	Reject
+)
+
+type Options struct {
+	// QueueSize is the maximum number of records held in memory.
+	QueueSize int
+	// BatchSize is the number of records that triggers a flush.
+	BatchSize int
+	// FlushInterval is the longest a record waits before being flushed.
+	FlushInterval time.Duration
+	Policy        Policy
+}
+
+var DefaultOptions = Options{
+	QueueSize:     10000,
+	BatchSize:     500,
+	FlushInterval: time.Second,
+	Policy:        Drop,
+}
+
+// Queue buffers records in memory and writes them to the store in batches
+// from a single goroutine, so ingestion never waits on the database.
+type Queue struct {
+	store   store.DBClient
+	options Options
+
+	records chan *store.Record
+	quit    chan struct{}
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Uint64
+	// reported is the part of dropped that has been logged, it is only used
+	// by the writer.
+	reported uint64
+
+	// sites holds the domains known to have a site, sites are never deleted
+	sites sync.Map
+}
+
+func NewQueue(db store.DBClient, options Options) *Queue {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultOptions.QueueSize
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultOptions.BatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultOptions.FlushInterval
+	}
+
+	q := &Queue{
+		store:   db,
+		options: options,
+		records: make(chan *store.Record, options.QueueSize),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go q.run()
+
+	return q
+}
+
+// Enqueue adds a record to the queue without blocking. When the queue is full
+// the record is dropped, and ErrQueueFull is returned under the Reject policy
+// and ErrDropped under the Drop policy.
+func (q *Queue) Enqueue(r *store.Record) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.records <- r:
+		return nil
+	default:
+		q.dropped.Add(1)
+		if q.options.Policy == Reject {
+			return ErrQueueFull
+		}
+		return ErrDropped
+	}
+}
+
+// Dropped returns the number of records discarded because the queue was full.
+func (q *Queue) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
+// CheckSite returns store.ErrSiteNotFound when there is no site for domain,
+// so events for it can be refused before they are queued. Known domains are
+// remembered, unknown ones are looked up every time so a new site is tracked
+// as soon as it is created.
+func (q *Queue) CheckSite(domain string) error {
+	if _, ok := q.sites.Load(domain); ok {
+		return nil
+	}
+	if _, err := q.store.GetSite(domain); err != nil {
+		return err
+	}
+	q.sites.Store(domain, struct{}{})
+	return nil
+}
+
+// Close stops accepting records, flushes everything still queued and waits
+// for the writer to finish.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.quit)
+	<-q.done
+
+	return nil
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*store.Record, 0, q.options.BatchSize)
+	for {
+		select {
+		case r := <-q.records:
+			batch = append(batch, r)
+			if len(batch) >= q.options.BatchSize {
+				batch = q.flush(batch)
+			}
+		case <-ticker.C:
+			batch = q.flush(batch)
+			q.reportDropped()
+		case <-q.quit:
+			// no more records can be enqueued, drain what is left
+			for {
+				select {
+				case r := <-q.records:
+					batch = append(batch, r)
+					if len(batch) >= q.options.BatchSize {
+						batch = q.flush(batch)
+					}
+				default:
+					q.flush(batch)
+					q.reportDropped()
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch to the store. When the batch fails the records are
+// written one by one, so a single bad record only loses itself.
+func (q *Queue) flush(batch []*store.Record) []*store.Record {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := q.store.InsertRecords(batch); err != nil {
+		log.Error().Err(err).Int("records", len(batch)).Msg("ingest: failed to write a batch, writing its records one by one")
+
+		for _, r := range batch {
+			if err := q.store.InsertRecords([]*store.Record{r}); err != nil {
+				log.Error().Err(err).Str("event", r.Event.EventName).Str("domain", r.Event.Domain).Msg("ingest: failed to write a record")
+			}
+		}
+	}
+
+	return batch[:0]
+}
+
+// reportDropped logs the records dropped since it was last called.
+func (q *Queue) reportDropped() {
+	dropped := q.dropped.Load()
+	if dropped == q.reported {
+		return
+	}
+	log.Warn().Uint64("dropped", dropped-q.reported).Uint64("total", dropped).Msg("ingest: queue full, records dropped")
+	q.reported = dropped
+}
@@ -0,0 +1,189 @@
+package ingest
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// fakeStore records what the queue writes, the rest of store.DBClient is not
+// used by the queue.
+type fakeStore struct {
+	store.DBClient
+
+	mu sync.Mutex
+	// written are the names of the events written, in order.
+	written []string
+	// inserts counts the calls to InsertRecords.
+	inserts int
+	// when release is set InsertRecords waits for it to be closed, and
+	// tells started that it is waiting.
+	started chan struct{}
+	release chan struct{}
+
+	sites   map[string]bool
+	lookups int
+}
+
+func (f *fakeStore) InsertRecords(records []*store.Record) error {
+	if f.release != nil {
+		select {
+		case f.started <- struct{}{}:
+		default:
+		}
+		<-f.release
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inserts++
+	// a batch with a bad event fails as a whole, like a transaction
+	for _, r := range records {
+		if r.Event.EventName == "bad" {
+			return errors.New("bad event")
+		}
+	}
+	for _, r := range records {
+		f.written = append(f.written, r.Event.EventName)
+	}
+	return nil
+}
+
+func (f *fakeStore) GetSite(domain string) (*store.Site, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	if !f.sites[domain] {
+		return nil, store.ErrSiteNotFound
+	}
+	return &store.Site{Domain: domain}, nil
+}
+
+func (f *fakeStore) events() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.written)
+}
+
+func record(name string) *store.Record {
+	ev := event.NewWEvent("example.com", "s1")
+	ev.EventName = name
+	return &store.Record{Session: &event.Session{SessionID: "s1", Domain: "example.com"}, Event: ev}
+}
+
+func TestQueueFull(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   error
+	}{
+		{Drop, ErrDropped},
+		{Reject, ErrQueueFull},
+	}
+	for _, tt := range tests {
+		f := &fakeStore{started: make(chan struct{}, 1), release: make(chan struct{})}
+		q := NewQueue(f, Options{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour, Policy: tt.policy})
+
+		// the writer holds the first record while the second fills the queue
+		if err := q.Enqueue(record("first")); err != nil {
+			t.Fatal(err)
+		}
+		<-f.started
+		if err := q.Enqueue(record("second")); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Enqueue(record("third")); !errors.Is(err, tt.want) {
+			t.Errorf("policy %d: Enqueue on a full queue error = %v, want %v", tt.policy, err, tt.want)
+		}
+		if q.Dropped() != 1 {
+			t.Errorf("policy %d: dropped = %d, want 1", tt.policy, q.Dropped())
+		}
+
+		close(f.release)
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := f.events(), []string{"first", "second"}; !slices.Equal(got, want) {
+			t.Errorf("policy %d: written = %v, want %v", tt.policy, got, want)
+		}
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	f := &fakeStore{}
+	// nothing is flushed before Close, by size or by time
+	q := NewQueue(f, Options{QueueSize: 10, BatchSize: 4, FlushInterval: time.Hour})
+
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := q.Enqueue(record(name)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, name)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.events(); !slices.Equal(got, want) {
+		t.Errorf("written after Close = %v, want %v", got, want)
+	}
+
+	if err := q.Enqueue(record("late")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Close error = %v, want %v", err, ErrQueueClosed)
+	}
+	if err := q.Close(); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("second Close error = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	f := &fakeStore{}
+	q := NewQueue(f, Options{QueueSize: 10, BatchSize: 3, FlushInterval: time.Hour})
+
+	for _, name := range []string{"a", "bad", "b"} {
+		if err := q.Enqueue(record(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.events(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("written = %v, want %v", got, want)
+	}
+	// the failed batch, then each of its records
+	if f.inserts != 4 {
+		t.Errorf("InsertRecords called %d times, want 4", f.inserts)
+	}
+}
+
+func TestCheckSite(t *testing.T) {
+	f := &fakeStore{sites: map[string]bool{"example.com": true}}
+	q := NewQueue(f, DefaultOptions)
+	defer q.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := q.CheckSite("example.com"); err != nil {
+			t.Errorf("CheckSite(example.com) error = %v", err)
+		}
+	}
+	if f.lookups != 1 {
+		t.Errorf("a known site was looked up %d times, want 1", f.lookups)
+	}
+
+	if err := q.CheckSite("new.com"); !errors.Is(err, store.ErrSiteNotFound) {
+		t.Errorf("CheckSite(new.com) error = %v, want %v", err, store.ErrSiteNotFound)
+	}
+	// an unknown site is tracked as soon as it is created
+	f.mu.Lock()
+	f.sites["new.com"] = true
+	f.mu.Unlock()
+	if err := q.CheckSite("new.com"); err != nil {
+		t.Errorf("CheckSite(new.com) after creating it error = %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package realtime
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+)
+
+// Event is what subscribers are sent for every tracked event, without
+// anything that identifies the visitor.
+type Event struct {
+	Site    string    `json:"site"`
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Source  string    `json:"source"`
+	Country string    `json:"country"`
+	Browser string    `json:"browser"`
+	At      time.Time `json:"at"`
+}
+
+func NewEvent(s *event.Session, ev *event.WEvent) *Event {
+	e := &Event{
+		Site:    ev.Domain,
+		Name:    ev.EventName,
+		Source:  ev.ReferrerSource,
+		Country: s.Country,
+		Browser: s.Browser,
+		At:      ev.CreatedAt,
+	}
+	if u, err := url.Parse(ev.Url); err == nil {
+		e.Path = u.Path
+	}
+	return e
+}
+
+// BufferSize is how many events a subscriber can fall behind by before the
+// events it has no room for are dropped.
+const BufferSize = 256
+
+// Hub passes events from ingestion to the subscribers of their site. Publish
+// never waits on a subscriber, so a slow one only misses events.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+type Subscription struct {
+	Site string
+	// C is closed when the subscription ends.
+	C <-chan *Event
+
+	events  chan *Event
+	dropped atomic.Uint64
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the events of site, which must be
+// ended with Unsubscribe.
+func (h *Hub) Subscribe(site string) *Subscription {
+	events := make(chan *Event, BufferSize)
+	sub := &Subscription{Site: site, C: events, events: events}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(events)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Publish sends e to every subscriber of its site that has room for it.
+func (h *Hub) Publish(e *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if sub.Site != e.Site {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Close ends every subscription, and the ones made after it end at once.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Dropped returns the number of events the subscriber had no room for.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
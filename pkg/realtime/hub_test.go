@@ -0,0 +1,153 @@
+package realtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+)
+
+// received returns the events waiting in sub without blocking.
+func received(sub *Subscription) []*Event {
+	var events []*Event
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// closed reports whether sub has ended once its events are read.
+func closed(sub *Subscription) bool {
+	received(sub)
+	select {
+	case _, ok := <-sub.C:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	h := NewHub()
+	a1, a2, b := h.Subscribe("a.com"), h.Subscribe("a.com"), h.Subscribe("b.com")
+
+	h.Publish(&Event{Site: "a.com", Name: "pageview"})
+	h.Publish(&Event{Site: "a.com", Name: "Signup"})
+	h.Publish(&Event{Site: "c.com", Name: "pageview"})
+
+	for _, sub := range []*Subscription{a1, a2} {
+		if got := received(sub); len(got) != 2 || got[0].Name != "pageview" || got[1].Name != "Signup" {
+			t.Errorf("a.com subscriber got %d events, want pageview then Signup", len(got))
+		}
+	}
+	if got := received(b); len(got) != 0 {
+		t.Errorf("b.com subscriber got %d events, want none", len(got))
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	slow, fast := h.Subscribe("a.com"), h.Subscribe("a.com")
+
+	for i := 0; i < BufferSize+10; i++ {
+		h.Publish(&Event{Site: "a.com"})
+		if i%2 == 0 {
+			received(fast)
+		}
+	}
+
+	if got := len(received(slow)); got != BufferSize {
+		t.Errorf("slow subscriber got %d events, want %d", got, BufferSize)
+	}
+	if got := slow.Dropped(); got != 10 {
+		t.Errorf("slow subscriber dropped %d events, want 10", got)
+	}
+	if got := fast.Dropped(); got != 0 {
+		t.Errorf("fast subscriber dropped %d events, want none", got)
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := NewHub()
+	sub, other := h.Subscribe("a.com"), h.Subscribe("a.com")
+
+	h.Unsubscribe(sub)
+	if !closed(sub) {
+		t.Error("subscription did not end")
+	}
+	// neither ending it again nor publishing to its site panics
+	h.Unsubscribe(sub)
+	h.Publish(&Event{Site: "a.com"})
+
+	if got := len(received(other)); got != 1 {
+		t.Errorf("other subscriber got %d events, want 1", got)
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub()
+	a, b := h.Subscribe("a.com"), h.Subscribe("b.com")
+
+	h.Close()
+	if !closed(a) || !closed(b) {
+		t.Error("Close did not end the subscriptions")
+	}
+
+	late := h.Subscribe("a.com")
+	if !closed(late) {
+		t.Error("subscription after Close did not end at once")
+	}
+	h.Publish(&Event{Site: "a.com"})
+	h.Unsubscribe(late)
+}
+
+func TestHubConcurrent(t *testing.T) {
+	h := NewHub()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Publish(&Event{Site: "a.com"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				sub := h.Subscribe("a.com")
+				received(sub)
+				h.Unsubscribe(sub)
+			}
+		}()
+	}
+	wg.Wait()
+	h.Close()
+}
+
+func TestNewEvent(t *testing.T) {
+	at := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	s := &event.Session{SessionID: "secret", Country: "NZ", Browser: "Firefox"}
+	ev := &event.WEvent{
+		Domain:         "example.com",
+		SessionID:      "secret",
+		EventName:      "pageview",
+		Url:            "https://example.com/blog/post",
+		ReferrerSource: "Google",
+		CreatedAt:      at,
+	}
+
+	want := Event{Site: "example.com", Name: "pageview", Path: "/blog/post", Source: "Google", Country: "NZ", Browser: "Firefox", At: at}
+	if got := NewEvent(s, ev); *got != want {
+		t.Errorf("NewEvent = %+v, want %+v", *got, want)
+	}
+}
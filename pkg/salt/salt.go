@@ -0,0 +1,157 @@
+package salt
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/rs/zerolog/log"
+)
+
+const day = 24 * time.Hour
+
+// Keeper holds the secret salts visitor ids are hashed with. A new random
+// salt is used every day (UTC), the previous one is kept for a grace period
+// after the rotation so that visits spanning midnight keep their id, and is
+// then wiped from memory and the store.
+//
+// Salts are stored so that every instance sharing a database, and the same
+// instance after a restart, hash visitors the same way.
+type Keeper struct {
+	store store.DBClient
+	grace time.Duration
+
+	mu       sync.RWMutex
+	day      time.Time
+	current  []byte
+	previous []byte
+	// pruned is the day before which salts were last deleted.
+	pruned time.Time
+}
+
+// NewKeeper loads or creates today's salt. Grace is how long the previous
+// salt is kept, usually the session timeout.
+func NewKeeper(db store.DBClient, grace time.Duration) (*Keeper, error) {
+	k := &Keeper{
+		store: db,
+		grace: grace,
+	}
+
+	if err := k.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Sum implements event.Salter.
+func (k *Keeper) Sum(b []byte) (string, string) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	current := event.KeyedHash(k.current, b)
+	if k.previous == nil {
+		return current, ""
+	}
+	return current, event.KeyedHash(k.previous, b)
+}
+
+// Run rotates the salts until ctx is done.
+func (k *Keeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := k.rotate(now); err != nil {
+				log.Error().Err(err).Msg("salt: failed to rotate")
+			}
+		}
+	}
+}
+
+func (k *Keeper) rotate(now time.Time) error {
+	now = now.UTC()
+	today := now.Truncate(day)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !today.Equal(k.day) {
+		current, err := k.load(today)
+		if err != nil {
+			return err
+		}
+
+		old := k.current
+		var previous []byte
+		if now.Sub(today) < k.grace {
+			if k.day.Equal(today.Add(-day)) {
+				previous, old = old, nil
+			} else if salt, err := k.store.GetSalt(today.Add(-day)); err == nil {
+				previous = salt.Value
+			} else if !errors.Is(err, store.ErrSaltNotFound) {
+				return err
+			}
+		}
+
+		wipe(old)
+		wipe(k.previous)
+		k.day = today
+		k.current = current
+		k.previous = previous
+	}
+
+	if k.previous != nil && now.Sub(today) >= k.grace {
+		wipe(k.previous)
+		k.previous = nil
+	}
+
+	// delete every salt that is no longer in use
+	before := today
+	if k.previous != nil {
+		before = today.Add(-day)
+	}
+	if !before.Equal(k.pruned) {
+		if err := k.store.DeleteSalts(before); err != nil {
+			return err
+		}
+		k.pruned = before
+	}
+
+	return nil
+}
+
+func (k *Keeper) load(today time.Time) ([]byte, error) {
+	salt, err := k.store.GetSalt(today)
+	if err == nil {
+		return salt.Value, nil
+	}
+	if !errors.Is(err, store.ErrSaltNotFound) {
+		return nil, err
+	}
+
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return nil, err
+	}
+
+	salt, err = k.store.CreateSalt(today, value)
+	if err != nil {
+		return nil, err
+	}
+	return salt.Value, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,180 @@
+package salt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// memStore keeps salts in memory, the rest of store.DBClient is not used by
+// the keeper.
+type memStore struct {
+	store.DBClient
+	salts map[time.Time][]byte
+	// deletes counts the calls to DeleteSalts.
+	deletes int
+}
+
+func newMemStore() *memStore {
+	return &memStore{salts: make(map[time.Time][]byte)}
+}
+
+func (m *memStore) CreateSalt(day time.Time, value []byte) (*store.Salt, error) {
+	if _, ok := m.salts[day]; !ok {
+		m.salts[day] = bytes.Clone(value)
+	}
+	return m.GetSalt(day)
+}
+
+// GetSalt returns a copy, as a database would, so the keeper wiping its
+// salts does not wipe the stored ones.
+func (m *memStore) GetSalt(day time.Time) (*store.Salt, error) {
+	value, ok := m.salts[day]
+	if !ok {
+		return nil, store.ErrSaltNotFound
+	}
+	return &store.Salt{Day: day, Value: bytes.Clone(value)}, nil
+}
+
+func (m *memStore) DeleteSalts(day time.Time) error {
+	m.deletes++
+	for d := range m.salts {
+		if d.Before(day) {
+			delete(m.salts, d)
+		}
+	}
+	return nil
+}
+
+func (m *memStore) days() []time.Time {
+	var days []time.Time
+	for d := range m.salts {
+		days = append(days, d)
+	}
+	return days
+}
+
+func mustRotate(t *testing.T, k *Keeper, now time.Time) {
+	t.Helper()
+	if err := k.rotate(now); err != nil {
+		t.Fatalf("rotate(%s): %v", now, err)
+	}
+}
+
+var visitor = []byte("192.0.2.1|Mozilla/5.0")
+
+// hash is the hash of visitor under the stored salt for day.
+func hash(t *testing.T, m *memStore, day time.Time) string {
+	t.Helper()
+	salt, err := m.GetSalt(day)
+	if err != nil {
+		t.Fatalf("salt for %s: %v", day.Format(time.DateOnly), err)
+	}
+	return event.KeyedHash(salt.Value, visitor)
+}
+
+func TestKeeperRotate(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(day)
+	m := newMemStore()
+	k := &Keeper{store: m, grace: 30 * time.Minute}
+
+	mustRotate(t, k, day1.Add(12*time.Hour))
+	current, previous := k.Sum(visitor)
+	if want := hash(t, m, day1); current != want || previous != "" {
+		t.Errorf("Sum on day 1 = %q, %q, want %q, \"\"", current, previous, want)
+	}
+
+	// the same day keeps the salt
+	mustRotate(t, k, day1.Add(23*time.Hour))
+	if again, _ := k.Sum(visitor); again != current {
+		t.Errorf("Sum later on day 1 = %q, want %q", again, current)
+	}
+
+	// just after midnight the previous salt is still used
+	mustRotate(t, k, day2.Add(10*time.Minute))
+	current, previous = k.Sum(visitor)
+	if want := hash(t, m, day2); current != want {
+		t.Errorf("Sum on day 2 = %q, want %q", current, want)
+	}
+	if want := hash(t, m, day1); previous != want {
+		t.Errorf("previous Sum in the grace period = %q, want %q", previous, want)
+	}
+	if current == previous {
+		t.Error("day 2 has the salt of day 1")
+	}
+
+	// after the grace period it is wiped and deleted
+	mustRotate(t, k, day2.Add(30*time.Minute))
+	if _, previous = k.Sum(visitor); previous != "" {
+		t.Errorf("previous Sum after the grace period = %q, want none", previous)
+	}
+	if k.previous != nil {
+		t.Error("the previous salt is still held")
+	}
+	if days := m.days(); len(days) != 1 || !days[0].Equal(day2) {
+		t.Errorf("stored salts = %v, want only %s", days, day2.Format(time.DateOnly))
+	}
+}
+
+func TestKeeperPrune(t *testing.T) {
+	day0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day1 := day0.Add(day)
+	m := newMemStore()
+	for _, d := range []time.Time{day0.Add(-day), day0, day1} {
+		m.CreateSalt(d, []byte(d.String()))
+	}
+	k := &Keeper{store: m, grace: 30 * time.Minute}
+
+	// in the grace period every salt older than the previous one is deleted
+	mustRotate(t, k, day1.Add(5*time.Minute))
+	if _, ok := m.salts[day0.Add(-day)]; ok {
+		t.Error("a salt from two days ago is kept")
+	}
+	if _, ok := m.salts[day0]; !ok {
+		t.Error("the previous salt was deleted in the grace period")
+	}
+
+	// nothing changed, so the store is not asked again
+	deletes := m.deletes
+	mustRotate(t, k, day1.Add(6*time.Minute))
+	if m.deletes != deletes {
+		t.Errorf("DeleteSalts called %d more times, want 0", m.deletes-deletes)
+	}
+
+	mustRotate(t, k, day1.Add(31*time.Minute))
+	if _, ok := m.salts[day0]; ok {
+		t.Error("the previous salt is kept after the grace period")
+	}
+}
+
+func TestKeeperRestart(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(day)
+	m := newMemStore()
+
+	a := &Keeper{store: m, grace: 30 * time.Minute}
+	mustRotate(t, a, day1.Add(23*time.Hour))
+	mustRotate(t, a, day2.Add(time.Minute))
+	wantCurrent, wantPrevious := a.Sum(visitor)
+
+	// another instance, or the same one restarted, hashes visitors the same
+	// way, including with the previous salt in the grace period
+	b := &Keeper{store: m, grace: 30 * time.Minute}
+	mustRotate(t, b, day2.Add(2*time.Minute))
+	current, previous := b.Sum(visitor)
+	if current != wantCurrent || previous != wantPrevious {
+		t.Errorf("Sum after a restart = %q, %q, want %q, %q", current, previous, wantCurrent, wantPrevious)
+	}
+
+	// started after the grace period, the previous salt is not used
+	c := &Keeper{store: newMemStore(), grace: 30 * time.Minute}
+	c.store.CreateSalt(day1, []byte("day 1"))
+	mustRotate(t, c, day2.Add(time.Hour))
+	if _, previous := c.Sum(visitor); previous != "" {
+		t.Errorf("previous Sum when started after the grace period = %q, want none", previous)
+	}
+}
@@ -0,0 +1,25 @@
+package store
+
+// Breakdown is one row of a report that splits visits by a dimension such as
+// the country.
+type Breakdown struct {
+	Name string `json:"name"`
+	// Country is set for dimensions that only make sense within a country,
+	// such as the region.
+	Country  string `json:"country,omitempty"`
+	Visitors int    `json:"visitors"`
+	// Percentage is the share of all visitors in the report.
+	Percentage int `json:"percentage"`
+	Visits     int `json:"visits"`
+	Pageviews  int `json:"pageviews"`
+	// BounceRate is the percentage of visits with a single event.
+	BounceRate int `json:"bounce_rate"`
+	// VisitDuration is the average length of a visit in seconds.
+	VisitDuration int `json:"visit_duration"`
+	// CustomEvents is the number of visits with at least one custom event.
+	CustomEvents int `json:"custom_events"`
+	// Conversions is the number of visitors that reached any goal.
+	Conversions int `json:"conversions"`
+	// ConversionRate is the percentage of visitors that reached any goal.
+	ConversionRate float64 `json:"conversion_rate"`
+}
@@ -7,11 +7,98 @@ import (
 )
 
 type DBClient interface {
+	CreateSite(domain string) (*Site, error)
+	GetSite(domain string) (*Site, error)
+	GetSites() ([]*Site, error)
+	SetSitePrivacy(domain string, privacy Privacy) (*Site, error)
+
 	InsertSession(session *event.Session) error
-	InsertEvent(event *event.WEvent) error
+	InsertEvent(event *event.WEvent) (int64, error)
+	InsertRecords(records []*Record) error
+
+	GetStats(site string, from time.Time, to time.Time, filters Filters) (*Stats, error)
+	// GetGraph works out metrics for each interval between from and to, with
+	// days, weeks and months starting at midnight in loc.
+	GetGraph(site string, from time.Time, to time.Time, interval Interval, loc *time.Location, metrics []Metric, filters Filters) (*GraphStats, error)
+	// GetRetention groups the returning visitors of site into a cohort for
+	// each interval between from and to by their first visit, which must
+	// match filters, and counts how many of each came back in every interval
+	// after it up to to.
+	GetRetention(site string, from time.Time, to time.Time, interval Interval, loc *time.Location, filters Filters) (*Retention, error)
+	GetProps(site string, eventName string) ([]*Prop, error)
+	GetRevenues(site string, eventName string) ([]*Revenue, error)
+	// CreateGoal and the other goal methods only see the goals of site, a goal
+	// of another site is not found.
+	CreateGoal(site string, goal *Goal) (*Goal, error)
+	GetGoals(site string) ([]*Goal, error)
+	UpdateGoal(site string, goal *Goal) (*Goal, error)
+	DeleteGoal(site string, id int64) error
+	// GetGoalStats counts the conversions of every goal of site in the visits
+	// that started between from and to.
+	GetGoalStats(site string, from time.Time, to time.Time, filters Filters) ([]*GoalStats, error)
+	// CreateFunnel and the other funnel methods only see the funnels of site.
+	CreateFunnel(site string, funnel *Funnel) (*Funnel, error)
+	GetFunnel(site string, id int64) (*Funnel, error)
+	GetFunnels(site string) ([]*Funnel, error)
+	UpdateFunnel(site string, funnel *Funnel) (*Funnel, error)
+	DeleteFunnel(site string, id int64) error
+	// GetFunnelStats counts the sessions that went through the steps of the
+	// funnel in order between from and to.
+	GetFunnelStats(site string, id int64, from time.Time, to time.Time, filters Filters) (*FunnelStats, error)
+	// GetTotalRevenue and the other revenue reports sum the revenue of the
+	// events in the visits that started between from and to, in the currency
+	// of each event.
+	GetTotalRevenue(site string, from time.Time, to time.Time, filters Filters) ([]*RevenueTotal, error)
+	// GetRevenueByGoal splits revenue by the goals its events reach.
+	GetRevenueByGoal(site string, from time.Time, to time.Time, filters Filters) ([]*RevenueTotal, error)
+	GetRevenueBySource(site string, from time.Time, to time.Time, filters Filters) ([]*RevenueTotal, error)
+	// GetRevenueByCampaign leaves out visits without a utm_campaign.
+	GetRevenueByCampaign(site string, from time.Time, to time.Time, filters Filters) ([]*RevenueTotal, error)
+	// GetRealtime counts the sessions with an event since since, and the
+	// pages they viewed and the sources of their visits, at most limit of each.
+	GetRealtime(site string, since time.Time, limit int) (*Realtime, error)
+	GetFilteredCounts(site string, from time.Time, to time.Time) ([]*FilteredCount, error)
+	GetCountries(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetRegions(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	// GetSources splits visits by the source of their referrer, visits
+	// without one have an empty name.
+	GetSources(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	// GetReferrers splits the visits from source by their full referrer.
+	GetReferrers(site string, source string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	// GetUTMSources and the other UTM breakdowns split visits by the
+	// parameters of their first event, visits without one are left out.
+	GetUTMSources(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetUTMMediums(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetUTMCampaigns(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetUTMTerms(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetUTMContents(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetBrowsers(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	// GetBrowserVersions splits the visits from browser by its major version.
+	GetBrowserVersions(site string, browser string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetOperatingSystems(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	// GetOSVersions splits the visits from os by its version.
+	GetOSVersions(site string, os string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetScreenTypes(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetLanguages(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*Breakdown, error)
+	GetPages(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*PageStats, error)
+	GetEntryPages(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*PageStats, error)
+	GetExitPages(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*PageStats, error)
+	// GetOutboundLinks and the other link reports split the events of one
+	// kind in the visits that started between from and to by their url.
+	GetOutboundLinks(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*LinkStats, error)
+	GetFileDownloads(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*LinkStats, error)
+	// GetBrokenLinks splits the 404 events by the url that was not found.
+	GetBrokenLinks(site string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*LinkStats, error)
+	// GetBrokenLinkReferrers splits the 404 events of url by the page that
+	// linked to it, those without a referrer have an empty name.
+	GetBrokenLinkReferrers(site string, url string, from time.Time, to time.Time, filters Filters, opts ListOptions) ([]*LinkStats, error)
 
-	GetStats(from time.Time, to time.Time) (*Stats, error)
-	GetViewsAndVisits(period string, from time.Time, to time.Time) (*GraphStats, error)
+	// CreateSalt stores value as the salt for day unless there already is one,
+	// and returns the salt that is kept for day.
+	CreateSalt(day time.Time, value []byte) (*Salt, error)
+	GetSalt(day time.Time) (*Salt, error)
+	// DeleteSalts overwrites and deletes every salt for a day before day.
+	DeleteSalts(day time.Time) error
 
-	// Close() error
+	Close() error
 }
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Op is the comparison of a filter.
+type Op string
+
+const (
+	// OpEq matches any of the values, a "*" in a value matches any text.
+	OpEq Op = "=="
+	// OpNeq matches none of the values.
+	OpNeq Op = "!="
+	// OpMatch matches any of the values as regular expressions. The patterns
+	// are limited to the syntax that Go and PostgreSQL read the same way:
+	// literals, ".", "^", "$", groups and "(?:" groups, bracket expressions
+	// with "[:alpha:]" style classes, the *, +, ? and {n,m} repetitions and
+	// their non-greedy forms, \d, \s, \w and their negations, \n, \r, \t,
+	// and a backslash before punctuation. "|" separates values, so
+	// alternatives are written as values.
+	OpMatch Op = "~"
+	// OpNotMatch matches none of the values as regular expressions.
+	OpNotMatch Op = "!~"
+)
+
+// ops is in the order operators are looked for, so "!=" is not read as "!"
+// followed by "=".
+var ops = []Op{OpEq, OpNeq, OpNotMatch, OpMatch}
+
+// Filter limits reports to the visits where Dimension compares to Values.
+type Filter struct {
+	Dimension string
+	Op        Op
+	Values    []string
+}
+
+// Filters are combined with AND.
+type Filters []Filter
+
+// FilterError is returned for filter expressions that cannot be parsed.
+type FilterError struct {
+	// Filter is the expression that is invalid.
+	Filter string `json:"filter"`
+	// Position is the byte offset of the expression in the parameter.
+	Position int    `json:"position"`
+	Message  string `json:"error"`
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter %q: %s", e.Filter, e.Message)
+}
+
+// PropPrefix is the prefix of dimensions that filter on a prop, e.g.
+// "props.plan==pro".
+const PropPrefix = "props."
+
+type dimensionKind int
+
+const (
+	// visitDimension is a column of visits.
+	visitDimension dimensionKind = iota
+	// sessionDimension is a column of the session of the visit.
+	sessionDimension
+	// eventDimension matches when any event of the visit matches.
+	eventDimension
+)
+
+type dimension struct {
+	kind   dimensionKind
+	column string
+	// path is set for urls that are compared by their path.
+	path bool
+}
+
+var dimensions = map[string]dimension{
+	"page":            {eventDimension, "url", true},
+	"event":           {eventDimension, "event_name", false},
+	"entry_page":      {visitDimension, "entry_url", true},
+	"exit_page":       {visitDimension, "exit_url", true},
+	"source":          {visitDimension, "referrer_source", false},
+	"referrer":        {visitDimension, "referrer", false},
+	"utm_source":      {visitDimension, "utm_source", false},
+	"utm_medium":      {visitDimension, "utm_medium", false},
+	"utm_campaign":    {visitDimension, "utm_campaign", false},
+	"utm_term":        {visitDimension, "utm_term", false},
+	"utm_content":     {visitDimension, "utm_content", false},
+	"country":         {sessionDimension, "country", false},
+	"region":          {sessionDimension, "region", false},
+	"city":            {sessionDimension, "city", false},
+	"browser":         {sessionDimension, "browser", false},
+	"browser_version": {sessionDimension, "browser_version", false},
+	"os":              {sessionDimension, "os", false},
+	"os_version":      {sessionDimension, "os_version", false},
+	"screen":          {sessionDimension, "screen_type", false},
+	"language":        {sessionDimension, "language", false},
+}
+
+var propKey = regexp.MustCompile(`^[A-Za-z0-9_\-.$]+$`)
+
+// ParseFilters parses filter expressions separated by ";", such as
+// "page==/blog/*;country==AU|NZ;utm_source!=newsletter;browser~Chrome". An
+// empty string has no filters.
+func ParseFilters(s string) (Filters, error) {
+	var filters Filters
+	pos := 0
+	for _, expr := range strings.Split(s, ";") {
+		start := pos
+		pos += len(expr) + 1
+
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+
+		f, msg := parseFilter(expr)
+		if msg != "" {
+			return nil, &FilterError{Filter: expr, Position: start, Message: msg}
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func parseFilter(expr string) (Filter, string) {
+	at, op := -1, Op("")
+	for _, o := range ops {
+		if i := strings.Index(expr, string(o)); i >= 0 && (at < 0 || i < at) {
+			at, op = i, o
+		}
+	}
+	if at < 0 {
+		return Filter{}, "missing operator, must be one of ==, !=, ~ or !~"
+	}
+
+	f := Filter{
+		Dimension: strings.TrimSpace(expr[:at]),
+		Op:        op,
+		Values:    strings.Split(expr[at+len(op):], "|"),
+	}
+
+	if key, ok := strings.CutPrefix(f.Dimension, PropPrefix); ok {
+		if !propKey.MatchString(key) {
+			return Filter{}, "invalid prop name"
+		}
+	} else if _, ok := dimensions[f.Dimension]; !ok {
+		return Filter{}, fmt.Sprintf("unknown dimension %q", f.Dimension)
+	}
+
+	if op == OpMatch || op == OpNotMatch {
+		for _, v := range f.Values {
+			if _, err := regexp.Compile(v); err != nil {
+				return Filter{}, err.Error()
+			}
+			if msg := checkPattern(v); msg != "" {
+				return Filter{}, msg
+			}
+		}
+	}
+
+	return f, ""
+}
+
+// checkPattern returns why a pattern that compiles is outside the syntax of
+// OpMatch, or "" when it is inside. Go and PostgreSQL disagree on the rest,
+// e.g. \b is a word boundary in Go and a backspace in PostgreSQL.
+func checkPattern(pattern string) string {
+	bracket := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+			e := pattern[i]
+			escapes := "dswDSWnrt"
+			if bracket {
+				escapes = "dswnrt"
+			}
+			if isAlnum(e) && strings.IndexByte(escapes, e) < 0 {
+				return fmt.Sprintf(`\%c is not supported in every database`, e)
+			}
+		case bracket:
+			if strings.HasPrefix(pattern[i:], "[:") {
+				i += strings.Index(pattern[i:], ":]") + 1
+			} else if c == ']' {
+				bracket = false
+			}
+		case c == '[':
+			bracket = true
+			// a ] first in the brackets is a literal
+			if strings.HasPrefix(pattern[i+1:], "^]") {
+				i += 2
+			} else if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+			}
+		case strings.HasPrefix(pattern[i:], "(?") && !strings.HasPrefix(pattern[i:], "(?:"):
+			return "flags and named groups are not supported in every database"
+		}
+	}
+	return ""
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Dialect is how a database spells the parts of a filter condition.
+type Dialect struct {
+	// Param returns the placeholder of the nth argument.
+	Param func(n int) string
+	// Regexp is the operator that matches text against a regular expression.
+	Regexp string
+	// Path returns the expression for the path of the url in column, which is
+	// the url after its scheme and host starting with a single "/", so a url
+	// without a path has the path "/".
+	Path func(column string) string
+}
+
+// Compile returns a condition on the visits table that is true for the visits
+// matching every filter, or "TRUE" when there are none. The placeholders of
+// args are numbered from n.
+func (fs Filters) Compile(d Dialect, n int) (string, []interface{}) {
+	if len(fs) == 0 {
+		return "TRUE", nil
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	param := func(v interface{}) string {
+		args = append(args, v)
+		return d.Param(n + len(args) - 1)
+	}
+
+	for _, f := range fs {
+		negate := f.Op == OpNeq || f.Op == OpNotMatch
+
+		// events and props are matched with EXISTS, so a negated filter is a
+		// visit without any matching event
+		if key, ok := strings.CutPrefix(f.Dimension, PropPrefix); ok {
+			key := param(key)
+			conds = append(conds, exists(negate, fmt.Sprintf(
+				"SELECT 1 FROM events AS filter_events JOIN props AS filter_props ON filter_props.event_id = filter_events.id WHERE filter_events.visit_id = visits.id AND filter_props.key = %s AND %s",
+				key, f.match(d, "filter_props.value", param),
+			)))
+			continue
+		}
+
+		dim := dimensions[f.Dimension]
+		var table string
+		switch dim.kind {
+		case visitDimension:
+			table = "visits"
+		case sessionDimension:
+			table = "filter_sessions"
+		case eventDimension:
+			table = "filter_events"
+		}
+
+		column := "COALESCE(" + table + "." + dim.column + ", '')"
+		if dim.path {
+			column = d.Path(table + "." + dim.column)
+		}
+		match := f.match(d, column, param)
+
+		switch dim.kind {
+		case visitDimension:
+			if negate {
+				match = "NOT " + match
+			}
+			conds = append(conds, match)
+		case sessionDimension:
+			conds = append(conds, exists(negate,
+				"SELECT 1 FROM sessions AS filter_sessions WHERE filter_sessions.id = visits.session_id AND "+match,
+			))
+		case eventDimension:
+			conds = append(conds, exists(negate,
+				"SELECT 1 FROM events AS filter_events WHERE filter_events.visit_id = visits.id AND "+match,
+			))
+		}
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// match returns the condition that column matches any of the values.
+func (f Filter) match(d Dialect, column string, param func(interface{}) string) string {
+	var or []string
+	for _, v := range f.Values {
+		switch {
+		case f.Op == OpMatch || f.Op == OpNotMatch:
+			or = append(or, column+" "+d.Regexp+" "+param(v))
+		case strings.Contains(v, "*"):
+			or = append(or, column+" LIKE "+param(likePattern(v))+" ESCAPE '\\'")
+		default:
+			or = append(or, column+" = "+param(v))
+		}
+	}
+	return "(" + strings.Join(or, " OR ") + ")"
+}
+
+func exists(negate bool, query string) string {
+	if negate {
+		return "NOT EXISTS (" + query + ")"
+	}
+	return "EXISTS (" + query + ")"
+}
+
+// likePattern turns a value with "*" wildcards into a LIKE pattern.
+func likePattern(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(v)
+}
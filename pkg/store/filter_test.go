@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Filters
+		// err is the position of the invalid expression, -1 when it parses
+		err int
+	}{
+		{"empty", "", nil, -1},
+		{"blank expressions", " ; ;", nil, -1},
+		{"equals", "country==NZ", Filters{{"country", OpEq, []string{"NZ"}}}, -1},
+		{"any of", "country==AU|NZ", Filters{{"country", OpEq, []string{"AU", "NZ"}}}, -1},
+		{"not equals", "utm_source!=newsletter", Filters{{"utm_source", OpNeq, []string{"newsletter"}}}, -1},
+		{"matches", "browser~^Chrome", Filters{{"browser", OpMatch, []string{"^Chrome"}}}, -1},
+		{"does not match", "browser!~Firefox", Filters{{"browser", OpNotMatch, []string{"Firefox"}}}, -1},
+		{"first operator wins", "page==/a!=b", Filters{{"page", OpEq, []string{"/a!=b"}}}, -1},
+		{"dimension is trimmed", " page ==/blog/*", Filters{{"page", OpEq, []string{"/blog/*"}}}, -1},
+		{"prop", "props.plan==pro", Filters{{"props.plan", OpEq, []string{"pro"}}}, -1},
+		{"several", "page==/;country!=AU", Filters{
+			{"page", OpEq, []string{"/"}},
+			{"country", OpNeq, []string{"AU"}},
+		}, -1},
+		{"missing operator", "country", nil, 0},
+		{"unknown dimension", "page==/;colour==red", nil, 8},
+		{"invalid prop name", "props.a b==c", nil, 0},
+		{"invalid regexp", "browser~(", nil, 0},
+		{"portable regexp", `page~^/blog/(?:[[:digit:]]+)[^?(]*\.html$`, Filters{{"page", OpMatch, []string{`^/blog/(?:[[:digit:]]+)[^?(]*\.html$`}}}, -1},
+		{"portable classes", `browser~^\w+\s\d*[]\d.]$`, Filters{{"browser", OpMatch, []string{`^\w+\s\d*[]\d.]$`}}}, -1},
+		{"word boundary", `browser~\bChrome`, nil, 0},
+		{"unicode class", `city~^\pL+$`, nil, 0},
+		{"negated class in brackets", `browser~[\D]`, nil, 0},
+		{"flags", "browser~(?i)chrome", nil, 0},
+		{"named group", "browser~(?P<name>Chrome)", nil, 0},
+		{"second value", `country==NZ;browser~Chrome|\QEdge\E`, nil, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilters(tt.in)
+			if tt.err >= 0 {
+				var ferr *FilterError
+				if !errors.As(err, &ferr) {
+					t.Fatalf("ParseFilters(%q) error = %v, want a FilterError", tt.in, err)
+				}
+				if ferr.Position != tt.err {
+					t.Errorf("ParseFilters(%q) error at %d, want %d", tt.in, ferr.Position, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFilters(%q) error = %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilters(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	dialects := map[string]Dialect{
+		"sqlite": {
+			Param:  func(n int) string { return "?" + strconv.Itoa(n) },
+			Regexp: "REGEXP",
+			Path:   func(column string) string { return "path(" + column + ")" },
+		},
+		"postgres": {
+			Param:  func(n int) string { return "$" + strconv.Itoa(n) },
+			Regexp: "~",
+			Path:   func(column string) string { return "path(" + column + ")" },
+		},
+	}
+
+	tests := []struct {
+		name    string
+		filters string
+		want    map[string]string
+		args    []interface{}
+	}{
+		{
+			name:    "none",
+			filters: "",
+			want:    map[string]string{"sqlite": "TRUE", "postgres": "TRUE"},
+		},
+		{
+			name:    "visit column",
+			filters: "source==Google|Bing",
+			want: map[string]string{
+				"sqlite":   "(COALESCE(visits.referrer_source, '') = ?4 OR COALESCE(visits.referrer_source, '') = ?5)",
+				"postgres": "(COALESCE(visits.referrer_source, '') = $4 OR COALESCE(visits.referrer_source, '') = $5)",
+			},
+			args: []interface{}{"Google", "Bing"},
+		},
+		{
+			name:    "negated visit path",
+			filters: "entry_page!=/blog/*",
+			want: map[string]string{
+				"sqlite":   `NOT (path(visits.entry_url) LIKE ?4 ESCAPE '\')`,
+				"postgres": `NOT (path(visits.entry_url) LIKE $4 ESCAPE '\')`,
+			},
+			args: []interface{}{"/blog/%"},
+		},
+		{
+			name:    "session regexp",
+			filters: "browser~^Chrome",
+			want: map[string]string{
+				"sqlite":   "EXISTS (SELECT 1 FROM sessions AS filter_sessions WHERE filter_sessions.id = visits.session_id AND (COALESCE(filter_sessions.browser, '') REGEXP ?4))",
+				"postgres": "EXISTS (SELECT 1 FROM sessions AS filter_sessions WHERE filter_sessions.id = visits.session_id AND (COALESCE(filter_sessions.browser, '') ~ $4))",
+			},
+			args: []interface{}{"^Chrome"},
+		},
+		{
+			name:    "negated event",
+			filters: "event!=Signup",
+			want: map[string]string{
+				"sqlite":   "NOT EXISTS (SELECT 1 FROM events AS filter_events WHERE filter_events.visit_id = visits.id AND (COALESCE(filter_events.event_name, '') = ?4))",
+				"postgres": "NOT EXISTS (SELECT 1 FROM events AS filter_events WHERE filter_events.visit_id = visits.id AND (COALESCE(filter_events.event_name, '') = $4))",
+			},
+			args: []interface{}{"Signup"},
+		},
+		{
+			name:    "prop and country",
+			filters: "props.plan==pro;country==NZ",
+			want: map[string]string{
+				"sqlite":   "EXISTS (SELECT 1 FROM events AS filter_events JOIN props AS filter_props ON filter_props.event_id = filter_events.id WHERE filter_events.visit_id = visits.id AND filter_props.key = ?4 AND (filter_props.value = ?5)) AND EXISTS (SELECT 1 FROM sessions AS filter_sessions WHERE filter_sessions.id = visits.session_id AND (COALESCE(filter_sessions.country, '') = ?6))",
+				"postgres": "EXISTS (SELECT 1 FROM events AS filter_events JOIN props AS filter_props ON filter_props.event_id = filter_events.id WHERE filter_events.visit_id = visits.id AND filter_props.key = $4 AND (filter_props.value = $5)) AND EXISTS (SELECT 1 FROM sessions AS filter_sessions WHERE filter_sessions.id = visits.session_id AND (COALESCE(filter_sessions.country, '') = $6))",
+			},
+			args: []interface{}{"plan", "pro", "NZ"},
+		},
+		{
+			name:    "wildcards are escaped",
+			filters: `page==/100%_*`,
+			want: map[string]string{
+				"sqlite":   `EXISTS (SELECT 1 FROM events AS filter_events WHERE filter_events.visit_id = visits.id AND (path(filter_events.url) LIKE ?4 ESCAPE '\'))`,
+				"postgres": `EXISTS (SELECT 1 FROM events AS filter_events WHERE filter_events.visit_id = visits.id AND (path(filter_events.url) LIKE $4 ESCAPE '\'))`,
+			},
+			args: []interface{}{`/100\%\_%`},
+		},
+	}
+	for _, tt := range tests {
+		filters, err := ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for name, d := range dialects {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				cond, args := filters.Compile(d, 4)
+				if cond != tt.want[name] {
+					t.Errorf("Compile(%q) =\n%s\nwant\n%s", tt.filters, cond, tt.want[name])
+				}
+				if !reflect.DeepEqual(args, tt.args) {
+					t.Errorf("Compile(%q) args = %q, want %q", tt.filters, args, tt.args)
+				}
+			})
+		}
+	}
+}
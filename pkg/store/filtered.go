@@ -0,0 +1,8 @@
+package store
+
+// FilteredCount is the number of events from automated traffic filtered for
+// one reason.
+type FilteredCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
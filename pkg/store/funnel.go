@@ -0,0 +1,164 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrFunnelNotFound = errors.New("funnel not found")
+
+// MaxFunnelSteps is the most steps a funnel may have.
+const MaxFunnelSteps = 8
+
+// FunnelStep is reached by a custom event or a pageview of a path, PagePath
+// may use "*" to match any text as in goals.
+type FunnelStep struct {
+	Name      string `json:"name"`
+	EventName string `json:"event_name,omitempty"`
+	PagePath  string `json:"page_path,omitempty"`
+}
+
+// Funnel is an ordered list of steps a session is expected to go through.
+type Funnel struct {
+	ID    int64        `json:"id"`
+	Name  string       `json:"name"`
+	Steps []FunnelStep `json:"steps"`
+	// Window is how many seconds a session has from the first step to reach
+	// the others, zero is no limit.
+	Window    int       `json:"window"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the funnel and names the steps that have no name after
+// their event or path.
+func (f *Funnel) Validate() error {
+	switch {
+	case f.Name == "":
+		return errors.New("funnel needs a name")
+	case len(f.Steps) < 2 || len(f.Steps) > MaxFunnelSteps:
+		return fmt.Errorf("funnel needs between 2 and %d steps", MaxFunnelSteps)
+	case f.Window < 0:
+		return errors.New("window cannot be negative")
+	}
+
+	for i := range f.Steps {
+		s := &f.Steps[i]
+		if err := validateTarget(fmt.Sprintf("step %d", i+1), s.EventName, s.PagePath); err != nil {
+			return err
+		}
+		if s.Name == "" {
+			s.Name = targetName(s.EventName, s.PagePath)
+		}
+	}
+	return nil
+}
+
+// Match returns a condition on the events table that is true for the events
+// that reach the step. The placeholders of args are numbered from n.
+func (s FunnelStep) Match(d Dialect, n int) (string, []interface{}) {
+	var args []interface{}
+	param := func(v interface{}) string {
+		args = append(args, v)
+		return d.Param(n + len(args) - 1)
+	}
+
+	if s.PagePath != "" {
+		f := Filter{Dimension: "page", Op: OpEq, Values: []string{s.PagePath}}
+		return "(events.event_name = 'pageview' AND " + f.match(d, d.Path("events.url"), param) + ")", args
+	}
+	f := Filter{Dimension: "event", Op: OpEq, Values: []string{s.EventName}}
+	return f.match(d, "events.event_name", param), args
+}
+
+// FunnelEvent is an event that reaches at least one step of a funnel, Steps
+// holds whether it reaches each step.
+type FunnelEvent struct {
+	SessionID string
+	At        time.Time
+	Steps     []bool
+}
+
+// Count returns how many sessions reached each step of the funnel after the
+// steps before it. events must be sorted by session and then time.
+func (f *Funnel) Count(events []FunnelEvent) []int {
+	counts := make([]int, len(f.Steps))
+	window := time.Duration(f.Window) * time.Second
+
+	var (
+		session string
+		// reached is whether the session reached each step, and starts is the
+		// latest time it reached the first step on its way there, as the latest
+		// start leaves the most of the window for the steps after
+		reached = make([]bool, len(f.Steps))
+		starts  = make([]time.Time, len(f.Steps))
+	)
+	flush := func() {
+		for k := range reached {
+			if reached[k] {
+				counts[k]++
+			}
+			reached[k] = false
+		}
+	}
+
+	for i, e := range events {
+		if i == 0 || e.SessionID != session {
+			flush()
+			session = e.SessionID
+		}
+
+		// the last step first, so one event cannot reach two steps in a row
+		for k := len(f.Steps) - 1; k >= 0; k-- {
+			switch {
+			case !e.Steps[k]:
+			case k == 0:
+				reached[0], starts[0] = true, e.At
+			case reached[k-1] && (window == 0 || e.At.Sub(starts[k-1]) <= window):
+				if !reached[k] || starts[k-1].After(starts[k]) {
+					starts[k] = starts[k-1]
+				}
+				reached[k] = true
+			}
+		}
+	}
+	flush()
+
+	return counts
+}
+
+type FunnelStepStats struct {
+	Step FunnelStep `json:"step"`
+	// Visitors is the number of sessions that reached the step in order.
+	Visitors int `json:"visitors"`
+	// ConversionRate is the percentage of the sessions that entered the
+	// funnel that reached the step.
+	ConversionRate float64 `json:"conversion_rate"`
+	// DropOff is the number of sessions that reached the step before but not
+	// this one, and DropOffRate is that as a percentage of the step before.
+	DropOff     int     `json:"drop_off"`
+	DropOffRate float64 `json:"drop_off_rate"`
+}
+
+type FunnelStats struct {
+	Funnel *Funnel            `json:"funnel"`
+	Steps  []*FunnelStepStats `json:"steps"`
+}
+
+// NewFunnelStats returns the rates of each step from the counts of Count.
+func NewFunnelStats(f *Funnel, counts []int) *FunnelStats {
+	stats := &FunnelStats{Funnel: f, Steps: make([]*FunnelStepStats, len(f.Steps))}
+	for k, step := range f.Steps {
+		st := &FunnelStepStats{
+			Step:           step,
+			Visitors:       counts[k],
+			ConversionRate: ConversionRate(counts[k], counts[0]),
+		}
+		if k > 0 {
+			st.DropOff = counts[k-1] - counts[k]
+			st.DropOffRate = ConversionRate(st.DropOff, counts[k-1])
+		}
+		stats.Steps[k] = st
+	}
+	return stats
+}
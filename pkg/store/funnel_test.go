@@ -0,0 +1,70 @@
+package store
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// funnelEvents reads events written as "session@minute:steps", e.g. "s1@5:13"
+// is an event of s1 five minutes in that reaches the first and third steps.
+func funnelEvents(t *testing.T, steps int, s string) []FunnelEvent {
+	t.Helper()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var events []FunnelEvent
+	for _, e := range strings.Fields(s) {
+		session, rest, _ := strings.Cut(e, "@")
+		minute, reached, _ := strings.Cut(rest, ":")
+		m, err := strconv.Atoi(minute)
+		if err != nil {
+			t.Fatalf("invalid event %q", e)
+		}
+
+		ev := FunnelEvent{SessionID: session, At: start.Add(time.Duration(m) * time.Minute), Steps: make([]bool, steps)}
+		for _, k := range reached {
+			ev.Steps[k-'1'] = true
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestFunnelCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		events string
+		want   []int
+	}{
+		{"no events", 0, "", []int{0, 0, 0}},
+		{"in order", 0, "s1@0:1 s1@1:2 s1@2:3", []int{1, 1, 1}},
+		{"out of order", 0, "s1@0:2 s1@1:1 s1@2:3", []int{1, 0, 0}},
+		{"skipped step", 0, "s1@0:1 s1@1:3", []int{1, 0, 0}},
+		{"repeated steps", 0, "s1@0:1 s1@1:1 s1@2:2 s1@3:2 s1@4:3", []int{1, 1, 1}},
+		{"sessions", 0, "s1@0:1 s1@1:2 s2@0:1 s3@0:2 s3@1:3", []int{2, 1, 0}},
+		{"no window", 0, "s1@0:1 s1@1000:2 s1@5000:3", []int{1, 1, 1}},
+		{"within window", 10 * time.Minute, "s1@0:1 s1@5:2 s1@10:3", []int{1, 1, 1}},
+		// the window runs from the first step, not the step before
+		{"outside window", 10 * time.Minute, "s1@0:1 s1@5:2 s1@11:3", []int{1, 1, 0}},
+		{"later start", 10 * time.Minute, "s1@0:1 s1@20:1 s1@25:2", []int{1, 1, 0}},
+		{"later run", 10 * time.Minute, "s1@0:1 s1@9:2 s1@30:1 s1@31:2 s1@35:3", []int{1, 1, 1}},
+		// one event does not reach two steps in a row
+		{"event on two steps", 0, "s1@0:12", []int{1, 0, 0}},
+		{"events on two steps", 0, "s1@0:12 s1@1:12", []int{1, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Funnel{
+				Steps:  []FunnelStep{{EventName: "a"}, {EventName: "b"}, {EventName: "c"}},
+				Window: int(tt.window / time.Second),
+			}
+			got := f.Count(funnelEvents(t, len(f.Steps), tt.events))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Count(%s) = %v, want %v", tt.events, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var ErrGoalNotFound = errors.New("goal not found")
+
+// Goal is what counts as a conversion on a site, either a custom event or a
+// pageview of a path. PagePath may use "*" to match any text, as in filters.
+type Goal struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	EventName string    `json:"event_name,omitempty"`
+	PagePath  string    `json:"page_path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks that the goal is either an event or a page goal, and names
+// it after its event or path when it has no name.
+func (g *Goal) Validate() error {
+	if err := validateTarget("goal", g.EventName, g.PagePath); err != nil {
+		return err
+	}
+	if g.Name == "" {
+		g.Name = targetName(g.EventName, g.PagePath)
+	}
+	return nil
+}
+
+// validateTarget checks that what is reached by either a custom event or a
+// pageview of a path.
+func validateTarget(what string, eventName string, pagePath string) error {
+	switch {
+	case eventName == "" && pagePath == "":
+		return fmt.Errorf("%s needs an event_name or a page_path", what)
+	case eventName != "" && pagePath != "":
+		return fmt.Errorf("%s cannot have both an event_name and a page_path", what)
+	case eventName == "pageview":
+		return fmt.Errorf("use page_path for a pageview %s", what)
+	case pagePath != "" && !strings.HasPrefix(pagePath, "/") && !strings.HasPrefix(pagePath, "*"):
+		return errors.New("page_path must start with / or *")
+	}
+	return nil
+}
+
+func targetName(eventName string, pagePath string) string {
+	if pagePath != "" {
+		return "Visit " + pagePath
+	}
+	return eventName
+}
+
+type GoalStats struct {
+	Goal *Goal `json:"goal"`
+	// UniqueConversions is the number of visitors that reached the goal.
+	UniqueConversions int `json:"unique_conversions"`
+	// TotalConversions is the number of times the goal was reached.
+	TotalConversions int `json:"total_conversions"`
+	// ConversionRate is the percentage of visitors that reached the goal.
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// RateDiff is a Diff of a percentage, Change is in percentage points.
+type RateDiff struct {
+	Value    float64  `json:"value"`
+	Previous float64  `json:"previous"`
+	Change   float64  `json:"change"`
+	Percent  *float64 `json:"percent"`
+}
+
+type GoalDiff struct {
+	Goal              *Goal     `json:"goal"`
+	UniqueConversions *Diff     `json:"unique_conversions"`
+	TotalConversions  *Diff     `json:"total_conversions"`
+	ConversionRate    *RateDiff `json:"conversion_rate"`
+}
+
+// CompareGoals compares the stats of each goal with its stats in prev, a nil
+// prev means there is nothing to compare with.
+func CompareGoals(goals []*GoalStats, prev []*GoalStats) []*GoalDiff {
+	before := make(map[int64]*GoalStats, len(prev))
+	for _, p := range prev {
+		before[p.Goal.ID] = p
+	}
+
+	diffs := make([]*GoalDiff, len(goals))
+	for i, g := range goals {
+		p, ok := before[g.Goal.ID]
+		if !ok {
+			diffs[i] = &GoalDiff{
+				Goal:              g.Goal,
+				UniqueConversions: &Diff{Value: g.UniqueConversions},
+				TotalConversions:  &Diff{Value: g.TotalConversions},
+				ConversionRate:    &RateDiff{Value: g.ConversionRate},
+			}
+			continue
+		}
+
+		diffs[i] = &GoalDiff{
+			Goal:              g.Goal,
+			UniqueConversions: newDiff(g.UniqueConversions, p.UniqueConversions),
+			TotalConversions:  newDiff(g.TotalConversions, p.TotalConversions),
+			ConversionRate: &RateDiff{
+				Value:    g.ConversionRate,
+				Previous: p.ConversionRate,
+				Change:   math.Round((g.ConversionRate-p.ConversionRate)*10) / 10,
+				Percent:  percentChange(g.ConversionRate, p.ConversionRate),
+			},
+		}
+	}
+	return diffs
+}
+
+// ConversionRate returns converted as a percentage of visitors, rounded to one
+// decimal place.
+func ConversionRate(converted int, visitors int) float64 {
+	if visitors == 0 {
+		return 0
+	}
+	return math.Round(float64(converted)*1000/float64(visitors)) / 10
+}
@@ -0,0 +1,11 @@
+package store
+
+// LinkStats is one row of the outbound link, file download or broken link
+// reports, which split the events of one kind by their url prop.
+type LinkStats struct {
+	Name     string `json:"name"`
+	Visitors int    `json:"visitors"`
+	// Percentage is the share of all visitors in the report.
+	Percentage int `json:"percentage"`
+	Events     int `json:"events"`
+}
@@ -0,0 +1,29 @@
+package store
+
+// PageStats is one row of the pages, entry pages or exit pages report.
+type PageStats struct {
+	Url       string `json:"url"`
+	Visitors  int    `json:"visitors"`
+	Pageviews int    `json:"pageviews"`
+	// BounceRate is the percentage of visits that started on the page and
+	// ended without another event.
+	BounceRate int `json:"bounce_rate"`
+	// TimeOnPage is the average engaged seconds of a pageview, from the
+	// engagement events, or for pages without them the seconds until the next
+	// pageview of the same visit.
+	TimeOnPage int `json:"time_on_page"`
+	// ScrollDepth is the average of how far the page was scrolled in each
+	// visit, as a percentage.
+	ScrollDepth int `json:"scroll_depth"`
+}
+
+// DefaultLimit is the number of rows returned when ListOptions.Limit is not set.
+const DefaultLimit = 100
+
+// ListOptions selects the order and the page of a report.
+type ListOptions struct {
+	// Sort is the column sorted on in descending order, e.g. "visitors".
+	Sort   string
+	Limit  int
+	Offset int
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/store/sqlstore"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+	sqldblogger "github.com/simukti/sqldb-logger"
+	"github.com/simukti/sqldb-logger/logadapter/zerologadapter"
+)
+
+//go:embed schema.sql
+var ddl string
+
+var dialect = sqlstore.Dialect{
+	Dialect: store.Dialect{
+		Param: func(n int) string {
+			return "$" + strconv.Itoa(n)
+		},
+		Regexp: "~",
+		Path: func(column string) string {
+			return "('/' || ltrim(regexp_replace(" + column + ", '^[^:]+://[^/?#]*', ''), '/'))"
+		},
+	},
+	Seconds: func(from, to string) string {
+		return "EXTRACT(EPOCH FROM " + to + " - " + from + ")"
+	},
+	Timestamp: func(param string) string {
+		return param + "::timestamptz"
+	},
+	ZeroBytes: func(column string) string {
+		return "decode(repeat('00', length(" + column + ")), 'hex')"
+	},
+	IsUniqueViolation: func(err error) bool {
+		var perr *pq.Error
+		return errors.As(err, &perr) && perr.Code == "23505"
+	},
+}
+
+func NewPostgres(dsn string) (store.DBClient, error) {
+	ctx := context.Background()
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	loggerAdapter := zerologadapter.New(zerolog.New(os.Stdout))
+	// the arguments are left out of the log, they include the secret salts
+	db = sqldblogger.OpenDriver(dsn, db.Driver(), loggerAdapter, sqldblogger.WithLogArguments(false)) // db is STILL *sql.DB
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return nil, err
+	}
+
+	return sqlstore.Open(db, &dialect)
+}
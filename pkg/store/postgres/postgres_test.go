@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/store/storetest"
+)
+
+// testDSN names the database the tests run against, they are skipped when it
+// is not set outside of CI. Each test gets a schema of its own that is
+// dropped after it.
+const testDSN = "GOTRACK_TEST_POSTGRES_DSN"
+
+var schemas atomic.Int64
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(testDSN)
+	if dsn == "" {
+		// CI provides a database, skipping there would hide the backend
+		if os.Getenv("CI") != "" {
+			t.Fatal(testDSN + " is not set")
+		}
+		t.Skip(testDSN + " is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Close()
+	})
+
+	storetest.Run(t, func(t *testing.T) store.DBClient {
+		schema := fmt.Sprintf("storetest_%d_%d", os.Getpid(), schemas.Add(1))
+		if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		})
+
+		db, err := NewPostgres(withSearchPath(dsn, schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+// withSearchPath adds search_path to dsn, which lib/pq passes on to the
+// server, for both the URL and the key=value forms.
+func withSearchPath(dsn string, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&search_path=" + schema
+		}
+		return dsn + "?search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
@@ -0,0 +1,140 @@
+CREATE TABLE IF NOT EXISTS sites (
+  id BIGSERIAL PRIMARY KEY,
+  domain TEXT NOT NULL UNIQUE,
+  privacy TEXT NOT NULL DEFAULT 'strict',
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  site_id BIGINT NOT NULL DEFAULT 0,
+  language TEXT,
+  country TEXT,
+  region TEXT,
+  city TEXT,
+  browser TEXT,
+  browser_version TEXT,
+  os TEXT,
+  os_version TEXT,
+  screen_type TEXT,
+  visitor_id TEXT,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+  id BIGSERIAL PRIMARY KEY,
+  site_id BIGINT NOT NULL DEFAULT 0,
+  session_id TEXT NOT NULL,
+  event_name TEXT NOT NULL,
+  url TEXT NOT NULL,
+  referrer TEXT,
+  utm_source TEXT,
+  utm_medium TEXT,
+  utm_campaign TEXT,
+  utm_term TEXT,
+  utm_content TEXT,
+  bot_reason TEXT,
+  visit_id BIGINT,
+  revenue_amount BIGINT,
+  revenue_currency TEXT,
+  engagement_time BIGINT,
+  scroll_depth INTEGER,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS visits (
+  id BIGSERIAL PRIMARY KEY,
+  site_id BIGINT NOT NULL,
+  session_id TEXT NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL,
+  ended_at TIMESTAMPTZ NOT NULL,
+  entry_url TEXT NOT NULL,
+  exit_url TEXT NOT NULL,
+  pageviews INTEGER NOT NULL DEFAULT 0,
+  events INTEGER NOT NULL DEFAULT 0,
+  is_bounce BOOLEAN NOT NULL,
+  referrer TEXT,
+  utm_source TEXT,
+  utm_medium TEXT,
+  utm_campaign TEXT,
+  utm_term TEXT,
+  utm_content TEXT,
+  referrer_source TEXT,
+  engagement_time BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS props (
+  id BIGSERIAL PRIMARY KEY,
+  event_id BIGINT NOT NULL,
+  key TEXT NOT NULL,
+  value TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS revenues (
+  id BIGSERIAL PRIMARY KEY,
+  event_id BIGINT NOT NULL,
+  key TEXT NOT NULL,
+  value TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS filtered_events (
+  site_id BIGINT NOT NULL,
+  reason TEXT NOT NULL,
+  day DATE NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (site_id, reason, day)
+);
+
+CREATE TABLE IF NOT EXISTS salts (
+  day DATE PRIMARY KEY NOT NULL,
+  value BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS goals (
+  id BIGSERIAL PRIMARY KEY,
+  site_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  event_name TEXT NOT NULL DEFAULT '',
+  page_path TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS funnels (
+  id BIGSERIAL PRIMARY KEY,
+  site_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  steps TEXT NOT NULL,
+  window_seconds INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+-- columns added after the first release
+ALTER TABLE events ADD COLUMN IF NOT EXISTS bot_reason TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS visit_id BIGINT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS region TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS city TEXT;
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS referrer_source TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS browser_version TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS os_version TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS revenue_amount BIGINT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS revenue_currency TEXT;
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS privacy TEXT NOT NULL DEFAULT 'strict';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS visitor_id TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS engagement_time BIGINT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS scroll_depth INTEGER;
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS engagement_time BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_session_site_id ON sessions (site_id);
+CREATE INDEX IF NOT EXISTS idx_session_visitor_id ON sessions (visitor_id);
+CREATE INDEX IF NOT EXISTS idx_event_site_id_created_at ON events (site_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_event_session_id ON events (session_id);
+CREATE INDEX IF NOT EXISTS idx_event_visit_id ON events (visit_id);
+CREATE INDEX IF NOT EXISTS idx_visit_session_id_ended_at ON visits (session_id, ended_at);
+CREATE INDEX IF NOT EXISTS idx_visit_site_id_started_at ON visits (site_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_prop_event_id ON props (event_id);
+CREATE INDEX IF NOT EXISTS idx_revenue_event_id ON revenues (event_id);
+CREATE INDEX IF NOT EXISTS idx_goal_site_id ON goals (site_id);
+CREATE INDEX IF NOT EXISTS idx_funnel_site_id ON funnels (site_id);
\ No newline at end of file
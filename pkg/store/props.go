@@ -0,0 +1,35 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Prop struct {
+	EventID   int64     `json:"event_id"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Revenue struct {
+	EventID   int64     `json:"event_id"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FormatValue converts a decoded JSON value into the string stored in the
+// props and revenues tables. Strings are stored as is, everything else is
+// stored as its JSON encoding.
+func FormatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
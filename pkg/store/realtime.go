@@ -0,0 +1,20 @@
+package store
+
+import "time"
+
+// RealtimeWindow is how recently a session must have sent an event to count
+// as a current visitor.
+const RealtimeWindow = 5 * time.Minute
+
+// Realtime is what the visitors of the last RealtimeWindow are doing.
+type Realtime struct {
+	// Visitors is the number of sessions with an event in the window.
+	Visitors int              `json:"visitors"`
+	Pages    []*RealtimeCount `json:"pages"`
+	Sources  []*RealtimeCount `json:"sources"`
+}
+
+type RealtimeCount struct {
+	Name     string `json:"name"`
+	Visitors int    `json:"visitors"`
+}
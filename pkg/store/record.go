@@ -0,0 +1,12 @@
+package store
+
+import "github.com/danecwalker/gotrack/pkg/event"
+
+// Record is a parsed event together with the session it belongs to, as
+// queued for insertion by the ingestion pipeline.
+type Record struct {
+	Session *event.Session
+	Event   *event.WEvent
+	// Discard counts the event as filtered traffic without storing it.
+	Discard bool
+}
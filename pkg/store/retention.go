@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Retention follows the visitors recognised by their visitor id, see
+// PrivacyReturning, from the interval of their first visit.
+type Retention struct {
+	Interval Interval  `json:"interval"`
+	Cohorts  []*Cohort `json:"cohorts"`
+}
+
+// Cohort is the visitors whose first visit started in the interval starting
+// at Start, an RFC 3339 time in the time zone of the request.
+type Cohort struct {
+	Start    string `json:"start"`
+	Visitors int    `json:"visitors"`
+	// Periods holds the visitors of the cohort that came back in each interval
+	// since, the first being the interval of Start itself, up to the end of the
+	// range.
+	Periods []*RetentionPeriod `json:"periods"`
+}
+
+type RetentionPeriod struct {
+	Visitors int `json:"visitors"`
+	// Percent is Visitors as a percentage of the cohort, rounded to one
+	// decimal place.
+	Percent float64 `json:"percent"`
+}
+
+// RetentionCount is the number of visitors of the cohort of bucket Cohort
+// with a visit in bucket Period.
+type RetentionCount struct {
+	Cohort   int
+	Period   int
+	Visitors int
+}
+
+// ParseRetentionInterval checks that s is an interval cohorts can be grouped
+// by, the default is a week.
+func ParseRetentionInterval(s string) (Interval, error) {
+	switch i := Interval(s); i {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+		return i, nil
+	case "":
+		return IntervalWeek, nil
+	}
+	return "", fmt.Errorf("invalid interval %q, must be day, week or month", s)
+}
+
+// NewRetention returns a cohort for every bucket, starts are the starts of the
+// buckets that counts refer to by index.
+func NewRetention(interval Interval, starts []time.Time, counts []*RetentionCount) *Retention {
+	retention := &Retention{
+		Interval: interval,
+		Cohorts:  make([]*Cohort, len(starts)),
+	}
+	for i, start := range starts {
+		periods := make([]*RetentionPeriod, len(starts)-i)
+		for p := range periods {
+			periods[p] = &RetentionPeriod{}
+		}
+		retention.Cohorts[i] = &Cohort{Start: start.Format(time.RFC3339), Periods: periods}
+	}
+
+	for _, c := range counts {
+		if c.Period < c.Cohort {
+			continue
+		}
+		retention.Cohorts[c.Cohort].Periods[c.Period-c.Cohort].Visitors = c.Visitors
+	}
+
+	for _, cohort := range retention.Cohorts {
+		// every visitor of a cohort visits in its first interval
+		cohort.Visitors = cohort.Periods[0].Visitors
+		if cohort.Visitors == 0 {
+			continue
+		}
+		for _, p := range cohort.Periods {
+			p.Percent = math.Round(float64(p.Visitors)*1000/float64(cohort.Visitors)) / 10
+		}
+	}
+	return retention
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"math"
+	"sort"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+)
+
+// RevenueTotal is the revenue of the events of one name in one currency.
+type RevenueTotal struct {
+	Name     string
+	Currency string
+	// Micros is the sum of the amounts, see event.MicrosPerUnit.
+	Micros      int64
+	Conversions int
+}
+
+// ExchangeRates is a static table of rates to the reporting Currency, Rates
+// holds how much one unit of each other currency is worth in it.
+type ExchangeRates struct {
+	Currency string
+	Rates    map[string]float64
+}
+
+// Rate returns the rate from currency to the reporting currency, and false
+// when there is none.
+func (x *ExchangeRates) Rate(currency string) (float64, bool) {
+	if currency == x.Currency {
+		return 1, true
+	}
+	r, ok := x.Rates[currency]
+	return r, ok
+}
+
+type RevenueStats struct {
+	// Name is the goal, source or campaign, and empty for the total.
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	// Total is in the reporting currency, rounded to cents.
+	Total float64 `json:"total"`
+	// Conversions is the number of events with revenue that could be
+	// converted, and Average is Total shared between them.
+	Conversions int     `json:"conversions"`
+	Average     float64 `json:"average"`
+	// Unconverted lists the currencies without a rate, whose revenue is left
+	// out.
+	Unconverted []string `json:"unconverted,omitempty"`
+}
+
+// Convert adds up the totals of each name in the reporting currency, most
+// revenue first.
+func (x *ExchangeRates) Convert(totals []*RevenueTotal) []*RevenueStats {
+	var (
+		stats  []*RevenueStats
+		byName = make(map[string]*RevenueStats)
+	)
+	for _, t := range totals {
+		st, ok := byName[t.Name]
+		if !ok {
+			st = &RevenueStats{Name: t.Name, Currency: x.Currency}
+			byName[t.Name] = st
+			stats = append(stats, st)
+		}
+
+		rate, ok := x.Rate(t.Currency)
+		if !ok {
+			st.Unconverted = append(st.Unconverted, t.Currency)
+			continue
+		}
+		st.Total += float64(t.Micros) / event.MicrosPerUnit * rate
+		st.Conversions += t.Conversions
+	}
+
+	for _, st := range stats {
+		if st.Conversions > 0 {
+			st.Average = roundCents(st.Total / float64(st.Conversions))
+		}
+		st.Total = roundCents(st.Total)
+		sort.Strings(st.Unconverted)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Total > stats[j].Total
+	})
+	return stats
+}
+
+// Total is Convert for totals that all have the same name.
+func (x *ExchangeRates) Total(totals []*RevenueTotal) *RevenueStats {
+	if stats := x.Convert(totals); len(stats) > 0 {
+		return stats[0]
+	}
+	return &RevenueStats{Currency: x.Currency}
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExchangeRatesConvert(t *testing.T) {
+	x := &ExchangeRates{
+		Currency: "USD",
+		Rates:    map[string]float64{"EUR": 1.1, "NZD": 0.6},
+	}
+
+	tests := []struct {
+		name   string
+		totals []*RevenueTotal
+		// want is each RevenueStats as name:total:conversions:average followed
+		// by its unconverted currencies
+		want string
+	}{
+		{"none", nil, ""},
+		{
+			"reporting currency",
+			[]*RevenueTotal{{Name: "Purchase", Currency: "USD", Micros: 30_000_000, Conversions: 3}},
+			"Purchase:30:3:10",
+		},
+		{
+			"converted",
+			[]*RevenueTotal{
+				{Name: "Purchase", Currency: "USD", Micros: 10_000_000, Conversions: 1},
+				{Name: "Purchase", Currency: "EUR", Micros: 10_000_000, Conversions: 1},
+				{Name: "Purchase", Currency: "NZD", Micros: 10_000_000, Conversions: 2},
+			},
+			"Purchase:27:4:6.75",
+		},
+		{
+			"rounded to cents",
+			[]*RevenueTotal{{Name: "Tip", Currency: "NZD", Micros: 1_234_567, Conversions: 3}},
+			"Tip:0.74:3:0.25",
+		},
+		{
+			"unconverted",
+			[]*RevenueTotal{
+				{Name: "Purchase", Currency: "JPY", Micros: 1_000_000_000, Conversions: 1},
+				{Name: "Purchase", Currency: "USD", Micros: 5_000_000, Conversions: 1},
+				{Name: "Purchase", Currency: "GBP", Micros: 5_000_000, Conversions: 1},
+			},
+			"Purchase:5:1:5 GBP JPY",
+		},
+		{
+			"only unconverted",
+			[]*RevenueTotal{{Name: "Purchase", Currency: "JPY", Micros: 1_000_000_000, Conversions: 1}},
+			"Purchase:0:0:0 JPY",
+		},
+		{
+			"most revenue first",
+			[]*RevenueTotal{
+				{Name: "Tip", Currency: "USD", Micros: 2_000_000, Conversions: 2},
+				{Name: "Purchase", Currency: "EUR", Micros: 100_000_000, Conversions: 1},
+				{Name: "Refund", Currency: "USD", Micros: -50_000_000, Conversions: 1},
+			},
+			"Purchase:110:1:110,Tip:2:2:1,Refund:-50:1:-50",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []string
+			for _, st := range x.Convert(tt.totals) {
+				if st.Currency != "USD" {
+					t.Errorf("%s is in %s, want USD", st.Name, st.Currency)
+				}
+				rows = append(rows, strings.TrimSpace(fmt.Sprintf("%s:%g:%d:%g %s", st.Name, st.Total, st.Conversions, st.Average, strings.Join(st.Unconverted, " "))))
+			}
+			if got := strings.Join(rows, ","); got != tt.want {
+				t.Errorf("Convert = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrSaltNotFound = errors.New("salt not found")
+
+// Salt is the secret visitor ids are hashed with for one day (UTC).
+type Salt struct {
+	Day       time.Time
+	Value     []byte
+	CreatedAt time.Time
+}
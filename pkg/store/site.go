@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrSiteNotFound = errors.New("site not found")
+	ErrSiteExists   = errors.New("site already exists")
+)
+
+// Privacy is what a site keeps about its visitors beyond the daily session.
+type Privacy string
+
+const (
+	// PrivacyStrict keeps nothing that lasts longer than the daily salt, so
+	// visitors cannot be recognised on another day.
+	PrivacyStrict Privacy = "strict"
+	// PrivacyReturning also keeps a hash of the visitor id the tag sends when
+	// it is opted in, so visitors can be recognised when they come back.
+	PrivacyReturning Privacy = "returning"
+)
+
+func ParsePrivacy(s string) (Privacy, error) {
+	switch p := Privacy(s); p {
+	case PrivacyStrict, PrivacyReturning:
+		return p, nil
+	case "":
+		return PrivacyStrict, nil
+	}
+	return "", fmt.Errorf("unknown privacy %q", s)
+}
+
+type Site struct {
+	ID        int64     `json:"id"`
+	Domain    string    `json:"domain"`
+	Privacy   Privacy   `json:"privacy"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type column struct {
+	table string
+	name  string
+	def   string
+}
+
+// columns lists the columns that were added to a table after it was first
+// created, so databases created by an older version can be brought up to date
+// before schema.sql is applied.
+var columns = []column{
+	{"sessions", "site_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"events", "site_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"events", "bot_reason", "TEXT"},
+	{"events", "visit_id", "INTEGER"},
+	{"sessions", "region", "TEXT"},
+	{"sessions", "city", "TEXT"},
+	{"visits", "referrer_source", "TEXT"},
+	{"sessions", "browser_version", "TEXT"},
+	{"sessions", "os_version", "TEXT"},
+	{"events", "revenue_amount", "INTEGER"},
+	{"events", "revenue_currency", "TEXT"},
+	{"sites", "privacy", "TEXT NOT NULL DEFAULT 'strict'"},
+	{"sessions", "visitor_id", "TEXT"},
+	{"events", "engagement_time", "INTEGER"},
+	{"events", "scroll_depth", "INTEGER"},
+	{"visits", "engagement_time", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	for _, c := range columns {
+		var total, found int
+		err := db.QueryRowContext(ctx,
+			"SELECT COUNT(*), COALESCE(SUM(name = ?), 0) FROM pragma_table_info(?)",
+			c.name, c.table,
+		).Scan(&total, &found)
+		if err != nil {
+			return err
+		}
+
+		// the table does not exist yet and will be created by schema.sql
+		if total == 0 || found > 0 {
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.def)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
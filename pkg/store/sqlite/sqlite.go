@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"errors"
 	"os"
-	"time"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
-	"github.com/danecwalker/gotrack/pkg/event"
 	"github.com/danecwalker/gotrack/pkg/store"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/danecwalker/gotrack/pkg/store/sqlstore"
+	"github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog"
 	sqldblogger "github.com/simukti/sqldb-logger"
 	"github.com/simukti/sqldb-logger/logadapter/zerologadapter"
@@ -18,222 +22,130 @@ import (
 //go:embed schema.sql
 var ddl string
 
-type Sqlite struct {
-	path string
-	ctx  context.Context
-	q    *Queries
-}
+// driverName is go-sqlite3 with a REGEXP function, which sqlite leaves to
+// the application, and a url_path function for the Path of the dialect.
+const driverName = "sqlite3_gotrack"
 
-func NewSqlite(path string) (store.DBClient, error) {
-	s := &Sqlite{
-		path: path,
-	}
-
-	if err := s.init(); err != nil {
-		return nil, err
-	}
-
-	return s, nil
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("regexp", matchRegexp, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("url_path", urlPath, true)
+		},
+	})
 }
 
-func (s *Sqlite) init() error {
-	s.ctx = context.Background()
-	// check if db exists and create if not
-	if _, err := os.Stat(s.path); os.IsNotExist(err) {
-		if _, err := os.Create(s.path); err != nil {
-			return err
+// urlPath implements url_path(url), the Path of the dialect, the same way as
+// the expression of the postgres dialect.
+func urlPath(url string) string {
+	if i := strings.Index(url, "://"); i > 0 && !strings.Contains(url[:i], ":") {
+		rest := url[i+3:]
+		if j := strings.IndexAny(rest, "/?#"); j >= 0 {
+			url = rest[j:]
+		} else {
+			url = ""
 		}
 	}
-
-	// open db connection ensure WAL mode is enabled and it is not locked
-	sq, err := sql.Open("sqlite3", s.path) //+"?_journal_mode=WAL&_busy_timeout=5000&cache=shared&rwc=3"
-	if err != nil {
-		return err
-	}
-	loggerAdapter := zerologadapter.New(zerolog.New(os.Stdout))
-	sq = sqldblogger.OpenDriver(s.path, sq.Driver(), loggerAdapter /*, using_default_options*/) // db is STILL *sql.DB
-
-	if _, err := sq.ExecContext(s.ctx, ddl); err != nil {
-		return err
-	}
-
-	queries := New(sq)
-
-	s.q = queries
-
-	return nil
+	return "/" + strings.TrimLeft(url, "/")
 }
 
-func (s *Sqlite) InsertEvent(ev *event.WEvent) error {
-	var err error
-	if ev.UTM == nil {
-		err = s.q.CreateEvent(s.ctx, CreateEventParams{
-			SessionID:   ev.SessionID,
-			EventName:   ev.EventName,
-			Url:         ev.Url,
-			Referrer:    sql.NullString{Valid: false},
-			UtmSource:   sql.NullString{Valid: false},
-			UtmMedium:   sql.NullString{Valid: false},
-			UtmCampaign: sql.NullString{Valid: false},
-			UtmTerm:     sql.NullString{Valid: false},
-			UtmContent:  sql.NullString{Valid: false},
-			CreatedAt:   time.Now().UTC(),
-		})
-	} else {
-		err = s.q.CreateEvent(s.ctx, CreateEventParams{
-			SessionID:   ev.SessionID,
-			EventName:   ev.EventName,
-			Url:         ev.Url,
-			Referrer:    sql.NullString{String: ev.Referrer, Valid: true},
-			UtmSource:   sql.NullString{String: ev.UTM.Source, Valid: true},
-			UtmMedium:   sql.NullString{String: ev.UTM.Medium, Valid: true},
-			UtmCampaign: sql.NullString{String: ev.UTM.Campaign, Valid: true},
-			UtmTerm:     sql.NullString{String: ev.UTM.Term, Valid: true},
-			UtmContent:  sql.NullString{String: ev.UTM.Content, Valid: true},
-			CreatedAt:   time.Now().UTC(),
-		})
-	}
-
-	// get event id
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
+// maxPatterns bounds the cache of matchRegexp, the patterns come from the
+// filters of requests and would otherwise be kept for the life of the process.
+const maxPatterns = 256
 
-func (s *Sqlite) InsertSession(session *event.Session) error {
+// patterns caches the compiled patterns of matchRegexp, filters use few
+// patterns that are matched against many rows.
+var (
+	patternsMu sync.Mutex
+	patterns   = make(map[string]*regexp.Regexp)
+)
 
-	err := s.q.CreateSession(s.ctx, CreateSessionParams{
-		ID:         session.SessionID,
-		Language:   sql.NullString{String: session.Language, Valid: true},
-		Country:    sql.NullString{String: session.Country, Valid: true},
-		Browser:    sql.NullString{String: session.Browser, Valid: true},
-		Os:         sql.NullString{String: session.Os, Valid: true},
-		ScreenType: sql.NullString{String: string(session.ScreenType), Valid: true},
-		CreatedAt:  time.Now().UTC(),
-	})
+// matchRegexp implements "text REGEXP pattern", filters have already checked
+// that the pattern compiles.
+func matchRegexp(pattern string, text string) (bool, error) {
+	patternsMu.Lock()
+	re, ok := patterns[pattern]
+	patternsMu.Unlock()
+
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, err
+		}
 
-	if err != nil {
-		return err
+		patternsMu.Lock()
+		// start over when the cache is full, the patterns still in use are
+		// compiled again on their next row
+		if len(patterns) >= maxPatterns {
+			clear(patterns)
+		}
+		patterns[pattern] = re
+		patternsMu.Unlock()
 	}
-
-	return nil
+	return re.MatchString(text), nil
 }
 
-func (s *Sqlite) GetStats(from time.Time, to time.Time) (*store.Stats, error) {
-	stats := &store.Stats{}
-
-	st, err := s.q.GetStats(s.ctx, GetStatsParams{
-		SessionTimeout: event.SessionTimeout,
-		From:           from,
-		To:             to,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if st.PageViews.Valid {
-		stats.PageViews = int(st.PageViews.Int64)
-	}
-
-	if st.UniqueVisitors.Valid {
-		stats.Visitors = int(st.UniqueVisitors.Int64)
-	}
-
-	if st.Bounces.Valid {
-		stats.Bounces = int(st.Bounces.Int64)
-	}
-
-	if st.AverageSessionLength.Valid {
-		stats.AverageSessionLength = int(st.AverageSessionLength.Int64)
-	}
-
-	return stats, nil
+var dialect = sqlstore.Dialect{
+	Dialect: store.Dialect{
+		Param: func(n int) string {
+			return "?" + strconv.Itoa(n)
+		},
+		// REGEXP is registered with the driver above
+		Regexp: "REGEXP",
+		Path: func(column string) string {
+			return "url_path(" + column + ")"
+		},
+	},
+	Seconds: func(from, to string) string {
+		return "(strftime('%s', " + to + ") - strftime('%s', " + from + "))"
+	},
+	// times are kept as text, a placeholder needs no type
+	Timestamp: func(param string) string {
+		return param
+	},
+	ZeroBytes: func(column string) string {
+		return "zeroblob(length(" + column + "))"
+	},
+	IsUniqueViolation: func(err error) bool {
+		var serr sqlite3.Error
+		return errors.As(err, &serr) && serr.ExtendedCode == sqlite3.ErrConstraintUnique
+	},
 }
 
-func (s *Sqlite) GetViewsAndVisits(period string, from time.Time, to time.Time) (*store.GraphStats, error) {
-	graph := &store.GraphStats{
-		Period: period,
+func NewSqlite(path string) (store.DBClient, error) {
+	ctx := context.Background()
+	// check if db exists and create if not, path may have options after a ?
+	file, options, _ := strings.Cut(path, "?")
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if _, err := os.Create(file); err != nil {
+			return nil, err
+		}
 	}
 
-	time_fmt := "%Y-%m-%d"
-	if period == "24h" {
-		time_fmt = "%Y-%m-%d %H:00:00.000000+00:00"
+	// secure_delete zeroes deleted content, so rotated salts are really gone
+	dsn := path + "?_secure_delete=true"
+	if options != "" {
+		dsn = path + "&_secure_delete=true"
 	}
 
-	res, err := s.q.GetViewsAndVisits(s.ctx, GetGraphParams{
-		SessionTimeout: event.SessionTimeout,
-		From:           from,
-		To:             to,
-		Format:         time_fmt,
-	})
-
+	// open db connection ensure WAL mode is enabled and it is not locked
+	sq, err := sql.Open(driverName, dsn) //+"?_journal_mode=WAL&_busy_timeout=5000&cache=shared&rwc=3"
 	if err != nil {
 		return nil, err
 	}
+	loggerAdapter := zerologadapter.New(zerolog.New(os.Stdout))
+	// the arguments are left out of the log, they include the secret salts
+	sq = sqldblogger.OpenDriver(dsn, sq.Driver(), loggerAdapter, sqldblogger.WithLogArguments(false)) // db is STILL *sql.DB
 
-	size := 30
-	switch period {
-	case "24h":
-		size = 24
-	case "7d":
-		size = 7
-	case "30d":
-		size = 30
+	if err := migrate(ctx, sq); err != nil {
+		return nil, err
 	}
 
-	graph.PageViews = make([]*store.Coord, size)
-	graph.Visitors = make([]*store.Coord, size)
-
-	var dCount int = 0
-	for i := 0; i < size; i++ {
-		graph.PageViews[i] = &store.Coord{}
-		graph.Visitors[i] = &store.Coord{}
-
-		var t time.Time
-		var format string
-		switch period {
-		case "24h":
-			format = "2006-01-02 15:04 +0000 UTC"
-			t = to.Add(-1 * time.Hour)
-		default:
-			format = "2006-01-02 00:00 +0000 UTC"
-			t = to.AddDate(0, 0, -1)
-		}
-
-		t = t.Add(-parsePeriod(period) * time.Duration(i))
-
-		graph.PageViews[i].X = t.Format(format)
-		graph.Visitors[i].X = t.Format(format)
-
-		if dCount < len(res) && res[dCount].Time.Compare(t) >= 0 {
-			if res[dCount].Views.Valid {
-				graph.PageViews[i].Y = int(res[dCount].Views.Int64)
-			} else {
-				graph.PageViews[i].Y = 0
-			}
-			if res[dCount].Visits.Valid {
-				graph.Visitors[i].Y = int(res[dCount].Visits.Int64)
-			} else {
-				graph.Visitors[i].Y = 0
-			}
-			dCount++
-		} else {
-			graph.PageViews[i].Y = 0
-			graph.Visitors[i].Y = 0
-		}
+	if _, err := sq.ExecContext(ctx, ddl); err != nil {
+		return nil, err
 	}
 
-	return graph, nil
-}
-
-func parsePeriod(p string) time.Duration {
-	switch p {
-	case "24h":
-		return time.Hour
-	default:
-		return 24 * time.Hour
-	}
+	return sqlstore.Open(sq, &dialect)
 }
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+	"github.com/danecwalker/gotrack/pkg/store/storetest"
+)
+
+func TestSqlite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.DBClient {
+		db, err := NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestNewSqliteOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewSqlite(path + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database file: %v", err)
+	}
+	if _, err := db.CreateSite("example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMatchRegexpCache(t *testing.T) {
+	for i := 0; i < maxPatterns*2; i++ {
+		ok, err := matchRegexp(fmt.Sprintf("^/page/%d$", i), fmt.Sprintf("/page/%d", i))
+		if err != nil || !ok {
+			t.Fatalf("matchRegexp(pattern %d) = %v, %v, want true", i, ok, err)
+		}
+	}
+
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	if len(patterns) > maxPatterns {
+		t.Errorf("%d patterns cached, want at most %d", len(patterns), maxPatterns)
+	}
+}
+
+func TestURLPath(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/blog/a":    "/blog/a",
+		"https://example.com":           "/",
+		"https://example.com/":          "/",
+		"https://example.com?ref=a":     "/?ref=a",
+		"https://example.com/?ref=a":    "/?ref=a",
+		"https://example.com#top":       "/#top",
+		"https://example.com:8080/a":    "/a",
+		"https://example.com//a":        "/a",
+		"/already/a/path":               "/already/a/path",
+		"":                              "/",
+		"mailto:someone@example.com":    "/mailto:someone@example.com",
+		"https://example.com/a://b/c?d": "/a://b/c?d",
+	}
+	for url, want := range tests {
+		if got := urlPath(url); got != want {
+			t.Errorf("urlPath(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -1,8 +1,4 @@
-// Code generated by sqlc. DO NOT EDIT.
-// versions:
-//   sqlc v1.25.0
-
-package sqlite
+package sqlstore
 
 import (
 	"context"
@@ -0,0 +1,101 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// Dialect is how a database spells the parts of the queries that are not
+// the same everywhere.
+//
+// Queries are written with $1 style placeholders, which are rewritten with
+// Param, and call the functions below from {{ }} actions:
+//
+//	{{seconds "started_at" "ended_at"}}
+//	{{path "events.url"}}
+//	{{regexp}}
+//	{{timestamp "$2"}}
+//	{{zeroBytes "value"}}
+type Dialect struct {
+	store.Dialect
+	// Seconds returns the number of seconds between two timestamp
+	// expressions.
+	Seconds func(from, to string) string
+	// Timestamp returns param typed as a timestamp, for where the database
+	// cannot tell the type of a placeholder.
+	Timestamp func(param string) string
+	// ZeroBytes returns a blob of zeroes as long as the blob in column.
+	ZeroBytes func(column string) string
+	// IsUniqueViolation reports whether err is the driver's error for a row
+	// that breaks a unique constraint.
+	IsUniqueViolation func(err error) bool
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// expand returns query in the dialect. Queries are part of the program, so
+// one that is not a valid template is a bug and panics.
+func (d *Dialect) expand(query string) string {
+	if strings.Contains(query, "{{") {
+		t := template.Must(template.New("query").Funcs(template.FuncMap{
+			"seconds":   d.Seconds,
+			"path":      d.Path,
+			"regexp":    func() string { return d.Regexp },
+			"timestamp": d.Timestamp,
+			"zeroBytes": d.ZeroBytes,
+		}).Parse(query))
+
+		var b strings.Builder
+		if err := t.Execute(&b, nil); err != nil {
+			panic(err)
+		}
+		query = b.String()
+	}
+
+	return placeholder.ReplaceAllStringFunc(query, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		return d.Param(n)
+	})
+}
+
+// utc returns args with every time in UTC. Times are stored in UTC, and
+// sqlite compares them as text, so a bound in the zone of a report would
+// select the wrong rows.
+func utc(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC()
+		}
+	}
+	return args
+}
+
+// dialectDB passes the queries to db in its dialect, with their times in
+// UTC.
+type dialectDB struct {
+	db DBTX
+	d  *Dialect
+}
+
+func (db dialectDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.db.ExecContext(ctx, db.d.expand(query), utc(args)...)
+}
+
+func (db dialectDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.db.PrepareContext(ctx, db.d.expand(query))
+}
+
+func (db dialectDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.db.QueryContext(ctx, db.d.expand(query), utc(args)...)
+}
+
+func (db dialectDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.db.QueryRowContext(ctx, db.d.expand(query), utc(args)...)
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+// The models are the rows of the tables in the schema.sql of each backend,
+// keep them in step with both.
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Event struct {
+	ID              int64
+	SiteID          int64
+	SessionID       string
+	EventName       string
+	Url             string
+	Referrer        sql.NullString
+	UtmSource       sql.NullString
+	UtmMedium       sql.NullString
+	UtmCampaign     sql.NullString
+	UtmTerm         sql.NullString
+	UtmContent      sql.NullString
+	BotReason       sql.NullString
+	VisitID         sql.NullInt64
+	RevenueAmount   sql.NullInt64
+	RevenueCurrency sql.NullString
+	EngagementTime  sql.NullInt64
+	ScrollDepth     sql.NullInt64
+	CreatedAt       time.Time
+}
+
+type FilteredEvent struct {
+	SiteID int64
+	Reason string
+	Day    time.Time
+	Count  int64
+}
+
+type Funnel struct {
+	ID            int64
+	SiteID        int64
+	Name          string
+	Steps         string
+	WindowSeconds int64
+	CreatedAt     time.Time
+}
+
+type Goal struct {
+	ID        int64
+	SiteID    int64
+	Name      string
+	EventName string
+	PagePath  string
+	CreatedAt time.Time
+}
+
+type Prop struct {
+	ID        int64
+	EventID   int64
+	Key       string
+	Value     string
+	CreatedAt time.Time
+}
+
+type Revenue struct {
+	ID        int64
+	EventID   int64
+	Key       string
+	Value     string
+	CreatedAt time.Time
+}
+
+type Salt struct {
+	Day       time.Time
+	Value     []byte
+	CreatedAt time.Time
+}
+
+type Session struct {
+	ID             string
+	SiteID         int64
+	Language       sql.NullString
+	Country        sql.NullString
+	Region         sql.NullString
+	City           sql.NullString
+	Browser        sql.NullString
+	BrowserVersion sql.NullString
+	Os             sql.NullString
+	OsVersion      sql.NullString
+	ScreenType     sql.NullString
+	VisitorID      sql.NullString
+	CreatedAt      time.Time
+}
+
+type Site struct {
+	ID        int64
+	Domain    string
+	Privacy   string
+	CreatedAt time.Time
+}
+
+type Visit struct {
+	ID             int64
+	SiteID         int64
+	SessionID      string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	EntryUrl       string
+	ExitUrl        string
+	Pageviews      int64
+	Events         int64
+	IsBounce       bool
+	Referrer       sql.NullString
+	UtmSource      sql.NullString
+	UtmMedium      sql.NullString
+	UtmCampaign    sql.NullString
+	UtmTerm        sql.NullString
+	UtmContent     sql.NullString
+	ReferrerSource sql.NullString
+	EngagementTime int64
+}
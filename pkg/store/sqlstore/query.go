@@ -0,0 +1,1130 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// dialect writes the filters for Dialect.expand, which puts them in the
+// dialect of the database with the rest of the query.
+var dialect = store.Dialect{
+	Param: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+	Regexp: "{{regexp}}",
+	Path: func(column string) string {
+		return `{{path "` + column + `"}}`
+	},
+}
+
+// withFilters puts the condition of filters in place of the filters comment
+// in query, and returns the query with its arguments.
+func withFilters(query string, filters store.Filters, args ...interface{}) (string, []interface{}) {
+	cond, filterArgs := filters.Compile(dialect, len(args)+1)
+	return strings.Replace(query, "/* filters */", cond, 1), append(args, filterArgs...)
+}
+
+const getStats = `-- name: GetStats :one
+SELECT
+	CAST(SUM(pageviews) AS BIGINT) AS pageviews,
+	COUNT(DISTINCT session_id) AS unique_visitors,
+	COUNT(*) FILTER (WHERE is_bounce) AS bounces,
+	CAST(AVG(CASE WHEN engagement_time > 0 THEN engagement_time / 1000 ELSE {{seconds "started_at" "ended_at"}} END) AS BIGINT) AS average_session_length
+FROM
+	visits
+WHERE
+	site_id = $1 AND started_at BETWEEN $2 AND $3 AND /* filters */
+`
+
+type GetStatsParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Filters store.Filters
+}
+
+type GetStatsResults struct {
+	PageViews            sql.NullInt64
+	UniqueVisitors       sql.NullInt64
+	Bounces              sql.NullInt64
+	AverageSessionLength sql.NullInt64
+}
+
+func (q *Queries) GetStats(ctx context.Context, arg GetStatsParams) (GetStatsResults, error) {
+	query, args := withFilters(getStats, arg.Filters,
+		arg.SiteID,
+		arg.From,
+		arg.To,
+	)
+	row := q.db.QueryRowContext(ctx, query, args...)
+	var i GetStatsResults
+	err := row.Scan(
+		&i.PageViews,
+		&i.UniqueVisitors,
+		&i.Bounces,
+		&i.AverageSessionLength,
+	)
+	return i, err
+}
+
+// getEngagement averages the engagement events of the pages viewed in the
+// visits that started between from and to. The engaged time of a page in a
+// visit is shared between its pageviews.
+const getEngagement = `-- name: GetEngagement :one
+WITH pages AS (
+	SELECT
+		events.visit_id,
+		events.url,
+		COUNT(*) AS pageviews
+	FROM
+		visits
+		JOIN events ON events.visit_id = visits.id AND events.event_name = 'pageview'
+	WHERE
+		visits.site_id = $1 AND visits.started_at BETWEEN $2 AND $3 AND /* filters */
+	GROUP BY
+		events.visit_id, events.url
+),
+engagement AS (
+	SELECT
+		pages.pageviews,
+		SUM(events.engagement_time) AS engagement_time,
+		MAX(events.scroll_depth) AS scroll_depth
+	FROM
+		pages
+		JOIN events ON events.visit_id = pages.visit_id AND events.url = pages.url AND events.event_name = 'engagement'
+	GROUP BY
+		pages.visit_id, pages.url, pages.pageviews
+)
+SELECT
+	CAST(COALESCE(SUM(engagement_time) / SUM(pageviews) / 1000, 0) AS BIGINT) AS time_on_page,
+	CAST(COALESCE(AVG(scroll_depth), 0) AS BIGINT) AS scroll_depth
+FROM
+	engagement
+`
+
+type GetEngagementRow struct {
+	TimeOnPage  int64
+	ScrollDepth int64
+}
+
+func (q *Queries) GetEngagement(ctx context.Context, arg GetStatsParams) (GetEngagementRow, error) {
+	query, args := withFilters(getEngagement, arg.Filters,
+		arg.SiteID,
+		arg.From,
+		arg.To,
+	)
+	row := q.db.QueryRowContext(ctx, query, args...)
+	var i GetEngagementRow
+	err := row.Scan(&i.TimeOnPage, &i.ScrollDepth)
+	return i, err
+}
+
+// getGraph sums the visits that started in each bucket, the %s are the
+// buckets and goalMatch. Bucket n of the edges of GetGraphParams is
+// (n, Edges[n], Edges[n+1]).
+const getGraph = `-- name: GetGraph :many
+WITH buckets (n, start_at, end_at) AS (
+	VALUES %s
+)
+SELECT
+	buckets.n,
+	COUNT(DISTINCT visits.session_id) AS visitors,
+	COUNT(visits.id) AS visits,
+	CAST(COALESCE(SUM(visits.pageviews), 0) AS BIGINT) AS pageviews,
+	COUNT(visits.id) FILTER (WHERE visits.is_bounce) AS bounces,
+	CAST(COALESCE(SUM(CASE WHEN visits.engagement_time > 0 THEN visits.engagement_time / 1000 ELSE {{seconds "visits.started_at" "visits.ended_at"}} END), 0) AS BIGINT) AS duration,
+	COUNT(visits.id) FILTER (WHERE visits.events > visits.pageviews) AS custom_events,
+	COUNT(DISTINCT CASE WHEN EXISTS (
+		SELECT 1 FROM events JOIN goals ON goals.site_id = visits.site_id AND %s
+		WHERE events.visit_id = visits.id
+	) THEN visits.session_id END) AS conversions
+FROM
+	buckets
+	LEFT JOIN visits ON visits.site_id = $1
+		AND visits.started_at >= buckets.start_at
+		AND visits.started_at < buckets.end_at
+		AND /* filters */
+GROUP BY
+	buckets.n
+ORDER BY
+	buckets.n ASC
+`
+
+// getGraphEvents counts the events of each name in the visits that started in
+// each bucket, the %s are the buckets and the names.
+const getGraphEvents = `-- name: GetGraphEvents :many
+WITH buckets (n, start_at, end_at) AS (
+	VALUES %s
+)
+SELECT
+	buckets.n,
+	events.event_name,
+	COUNT(*) AS count
+FROM
+	buckets
+	JOIN visits ON visits.site_id = $1
+		AND visits.started_at >= buckets.start_at
+		AND visits.started_at < buckets.end_at
+		AND /* filters */
+	JOIN events ON events.visit_id = visits.id AND events.event_name IN (%s)
+GROUP BY
+	buckets.n, events.event_name
+ORDER BY
+	buckets.n ASC
+`
+
+type GetGraphParams struct {
+	SiteID int64
+	// Edges are the bounds of the buckets, bucket n counts the visits that
+	// started from Edges[n] up to but not including Edges[n+1].
+	Edges   []time.Time
+	Filters store.Filters
+}
+
+type GetGraphRow struct {
+	Bucket       int
+	Visitors     int64
+	Visits       int64
+	Pageviews    int64
+	Bounces      int64
+	Duration     int64
+	CustomEvents int64
+	Conversions  int64
+}
+
+// bucketValues returns the rows of the buckets CTE and their arguments, after
+// the site id.
+func bucketValues(edges []time.Time) (string, []interface{}) {
+	params := make([]interface{}, 0, len(edges))
+	values := make([]string, 0, len(edges))
+	for n, edge := range edges {
+		// sqlite compares times as text, so they must be in UTC like the stored ones
+		params = append(params, edge.UTC())
+		if n > 0 {
+			values = append(values, fmt.Sprintf(`(%d, {{timestamp "$%d"}}, {{timestamp "$%d"}})`, n-1, n+1, n+2))
+		}
+	}
+	return strings.Join(values, ", "), params
+}
+
+func (q *Queries) GetGraph(ctx context.Context, arg GetGraphParams) ([]GetGraphRow, error) {
+	values, params := bucketValues(arg.Edges)
+	query, params := withFilters(fmt.Sprintf(getGraph, values, goalMatch), arg.Filters, append([]interface{}{arg.SiteID}, params...)...)
+	rows, err := q.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetGraphRow
+	for rows.Next() {
+		var i GetGraphRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Visitors,
+			&i.Visits,
+			&i.Pageviews,
+			&i.Bounces,
+			&i.Duration,
+			&i.CustomEvents,
+			&i.Conversions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type GetGraphEventsRow struct {
+	Bucket    int
+	EventName string
+	Count     int64
+}
+
+func (q *Queries) GetGraphEvents(ctx context.Context, arg GetGraphParams, names []string) ([]GetGraphEventsRow, error) {
+	values, params := bucketValues(arg.Edges)
+	params = append([]interface{}{arg.SiteID}, params...)
+	in := make([]string, len(names))
+	for i, name := range names {
+		in[i] = fmt.Sprintf("$%d", len(params)+1)
+		params = append(params, name)
+	}
+
+	query, params := withFilters(fmt.Sprintf(getGraphEvents, values, strings.Join(in, ", ")), arg.Filters, params...)
+	rows, err := q.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetGraphEventsRow
+	for rows.Next() {
+		var i GetGraphEventsRow
+		if err := rows.Scan(&i.Bucket, &i.EventName, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getRetention counts the visitors of each cohort that visited in each
+// bucket. A cohort is the visitors whose first visit ever started in a bucket
+// and matches the filters, the %s is the buckets.
+const getRetention = `-- name: GetRetention :many
+WITH buckets (n, start_at, end_at) AS (
+	VALUES %s
+),
+firsts AS (
+	SELECT
+		sessions.visitor_id,
+		MIN(visits.started_at) AS started_at
+	FROM
+		visits
+		JOIN sessions ON sessions.id = visits.session_id
+	WHERE
+		visits.site_id = $1 AND sessions.visitor_id IS NOT NULL
+	GROUP BY
+		sessions.visitor_id
+),
+cohorts AS (
+	SELECT DISTINCT
+		firsts.visitor_id,
+		buckets.n
+	FROM
+		firsts
+		JOIN buckets ON firsts.started_at >= buckets.start_at
+			AND firsts.started_at < buckets.end_at
+		JOIN sessions ON sessions.site_id = $1 AND sessions.visitor_id = firsts.visitor_id
+		JOIN visits ON visits.session_id = sessions.id AND visits.started_at = firsts.started_at
+	WHERE
+		/* filters */
+)
+SELECT
+	cohorts.n AS cohort,
+	buckets.n AS period,
+	COUNT(DISTINCT cohorts.visitor_id) AS visitors
+FROM
+	cohorts
+	JOIN sessions ON sessions.site_id = $1 AND sessions.visitor_id = cohorts.visitor_id
+	JOIN visits ON visits.session_id = sessions.id
+	JOIN buckets ON visits.started_at >= buckets.start_at
+		AND visits.started_at < buckets.end_at
+GROUP BY
+	cohorts.n, buckets.n
+ORDER BY
+	cohorts.n ASC, buckets.n ASC
+`
+
+type GetRetentionRow struct {
+	Cohort   int
+	Period   int
+	Visitors int64
+}
+
+func (q *Queries) GetRetention(ctx context.Context, arg GetGraphParams) ([]GetRetentionRow, error) {
+	values, params := bucketValues(arg.Edges)
+	query, params := withFilters(fmt.Sprintf(getRetention, values), arg.Filters, append([]interface{}{arg.SiteID}, params...)...)
+	rows, err := q.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRetentionRow
+	for rows.Next() {
+		var i GetRetentionRow
+		if err := rows.Scan(&i.Cohort, &i.Period, &i.Visitors); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getPageStats is shared by the page reports, the %s is the condition that
+// picks the pageviews of each visit that are counted.
+const getPageStats = `-- name: %s :many
+WITH pv AS (
+	SELECT
+		url,
+		session_id,
+		visit_id,
+		created_at,
+		LEAD(created_at) OVER w AS next_at,
+		ROW_NUMBER() OVER w AS n,
+		COUNT(*) OVER (PARTITION BY visit_id) AS total,
+		COUNT(*) OVER (PARTITION BY visit_id, url) AS views
+	FROM
+		events
+	WHERE
+		site_id = $1 AND event_name = 'pageview' AND visit_id IS NOT NULL AND created_at BETWEEN $2 AND $3
+	WINDOW w AS (PARTITION BY visit_id ORDER BY created_at, id)
+),
+engagement AS (
+	SELECT
+		visit_id,
+		url,
+		SUM(engagement_time) AS engagement_time,
+		MAX(scroll_depth) AS scroll_depth
+	FROM
+		events
+	WHERE
+		site_id = $1 AND event_name = 'engagement' AND visit_id IN (SELECT visit_id FROM pv)
+	GROUP BY
+		visit_id, url
+)
+SELECT * FROM (
+	SELECT
+		pv.url,
+		COUNT(DISTINCT pv.session_id) AS visitors,
+		COUNT(*) AS pageviews,
+		CAST(COALESCE(SUM(CASE WHEN pv.n = 1 AND visits.is_bounce THEN 1 ELSE 0 END) * 100 / NULLIF(SUM(CASE WHEN pv.n = 1 THEN 1 ELSE 0 END), 0), 0) AS BIGINT) AS bounce_rate,
+		CAST(COALESCE(AVG(engagement.engagement_time / pv.views) / 1000, AVG({{seconds "pv.created_at" "pv.next_at"}}), 0) AS BIGINT) AS time_on_page,
+		CAST(COALESCE(AVG(engagement.scroll_depth), 0) AS BIGINT) AS scroll_depth
+	FROM
+		pv
+	JOIN visits ON visits.id = pv.visit_id
+	LEFT JOIN engagement ON engagement.visit_id = pv.visit_id AND engagement.url = pv.url
+	WHERE
+		%s AND /* filters */
+	GROUP BY
+		pv.url
+) AS t
+ORDER BY
+	CASE $4
+		WHEN 'pageviews' THEN pageviews
+		WHEN 'bounce_rate' THEN bounce_rate
+		WHEN 'time_on_page' THEN time_on_page
+		WHEN 'scroll_depth' THEN scroll_depth
+		ELSE visitors
+	END DESC,
+	url ASC
+LIMIT $5 OFFSET $6
+`
+
+var (
+	getPages      = fmt.Sprintf(getPageStats, "GetPages", "pv.n > 0")
+	getEntryPages = fmt.Sprintf(getPageStats, "GetEntryPages", "pv.n = 1")
+	getExitPages  = fmt.Sprintf(getPageStats, "GetExitPages", "pv.n = pv.total")
+)
+
+type GetPageStatsParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Sort    string
+	Limit   int64
+	Offset  int64
+	Filters store.Filters
+}
+
+type GetPageStatsRow struct {
+	Url         string
+	Visitors    int64
+	Pageviews   int64
+	BounceRate  int64
+	TimeOnPage  int64
+	ScrollDepth int64
+}
+
+func (q *Queries) GetPages(ctx context.Context, arg GetPageStatsParams) ([]GetPageStatsRow, error) {
+	return q.getPageStats(ctx, getPages, arg)
+}
+
+func (q *Queries) GetEntryPages(ctx context.Context, arg GetPageStatsParams) ([]GetPageStatsRow, error) {
+	return q.getPageStats(ctx, getEntryPages, arg)
+}
+
+func (q *Queries) GetExitPages(ctx context.Context, arg GetPageStatsParams) ([]GetPageStatsRow, error) {
+	return q.getPageStats(ctx, getExitPages, arg)
+}
+
+func (q *Queries) getPageStats(ctx context.Context, query string, arg GetPageStatsParams) ([]GetPageStatsRow, error) {
+	query, args := withFilters(query, arg.Filters,
+		arg.SiteID,
+		arg.From,
+		arg.To,
+		arg.Sort,
+		arg.Limit,
+		arg.Offset,
+	)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPageStatsRow
+	for rows.Next() {
+		var i GetPageStatsRow
+		if err := rows.Scan(
+			&i.Url,
+			&i.Visitors,
+			&i.Pageviews,
+			&i.BounceRate,
+			&i.TimeOnPage,
+			&i.ScrollDepth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getBreakdown is shared by the reports that split visits by a dimension, the
+// %s are the name and country of each row, goalMatch and an extra condition on
+// the visits that are counted. Percentage is the share of the visitors of all
+// the rows, not only those on the page, and conversions are the visitors with
+// a visit that reached any goal.
+const getBreakdown = `-- name: %s :many
+WITH v AS (
+	SELECT
+		%s AS name,
+		%s AS country,
+		visits.session_id,
+		visits.started_at,
+		visits.ended_at,
+		visits.engagement_time,
+		visits.pageviews,
+		visits.events,
+		visits.is_bounce,
+		EXISTS (
+			SELECT 1 FROM events JOIN goals ON goals.site_id = visits.site_id AND %s
+			WHERE events.visit_id = visits.id
+		) AS converted
+	FROM
+		visits
+	JOIN sessions ON sessions.id = visits.session_id
+	WHERE
+		visits.site_id = $1 AND visits.started_at BETWEEN $2 AND $3%s AND /* filters */
+)
+SELECT * FROM (
+	SELECT
+		name,
+		country,
+		COUNT(DISTINCT session_id) AS visitors,
+		COUNT(DISTINCT session_id) * 100 / (SELECT COUNT(DISTINCT session_id) FROM v) AS percentage,
+		COUNT(*) AS visits,
+		CAST(SUM(pageviews) AS BIGINT) AS pageviews,
+		(COUNT(*) FILTER (WHERE is_bounce) * 100 / COUNT(*)) AS bounce_rate,
+		CAST(COALESCE(AVG(CASE WHEN engagement_time > 0 THEN engagement_time / 1000 ELSE {{seconds "started_at" "ended_at"}} END), 0) AS BIGINT) AS visit_duration,
+		COUNT(*) FILTER (WHERE events > pageviews) AS custom_events,
+		COUNT(DISTINCT CASE WHEN converted THEN session_id END) AS conversions
+	FROM
+		v
+	GROUP BY
+		name, country
+) AS t
+ORDER BY
+	CASE $4
+		WHEN 'visits' THEN visits
+		WHEN 'pageviews' THEN pageviews
+		WHEN 'bounce_rate' THEN bounce_rate
+		WHEN 'visit_duration' THEN visit_duration
+		WHEN 'custom_events' THEN custom_events
+		WHEN 'conversions' THEN conversions
+		ELSE visitors
+	END DESC,
+	name ASC,
+	country ASC
+LIMIT $5 OFFSET $6
+`
+
+// breakdown returns the getBreakdown query called name.
+func breakdown(name string, dimension string, country string, cond string) string {
+	return fmt.Sprintf(getBreakdown, name, dimension, country, goalMatch, cond)
+}
+
+var (
+	getCountries = breakdown("GetCountries", "COALESCE(sessions.country, '')", "''", "")
+	getRegions   = breakdown("GetRegions", "COALESCE(sessions.region, '')", "COALESCE(sessions.country, '')", "")
+	getSources   = breakdown("GetSources", "COALESCE(visits.referrer_source, '')", "''", "")
+	getReferrers = breakdown("GetReferrers", "COALESCE(visits.referrer, '')", "''", " AND visits.referrer_source = $7")
+
+	getUTMSources   = breakdown("GetUTMSources", "visits.utm_source", "''", " AND visits.utm_source != ''")
+	getUTMMediums   = breakdown("GetUTMMediums", "visits.utm_medium", "''", " AND visits.utm_medium != ''")
+	getUTMCampaigns = breakdown("GetUTMCampaigns", "visits.utm_campaign", "''", " AND visits.utm_campaign != ''")
+	getUTMTerms     = breakdown("GetUTMTerms", "visits.utm_term", "''", " AND visits.utm_term != ''")
+	getUTMContents  = breakdown("GetUTMContents", "visits.utm_content", "''", " AND visits.utm_content != ''")
+
+	getBrowsers         = breakdown("GetBrowsers", "COALESCE(sessions.browser, '')", "''", "")
+	getBrowserVersions  = breakdown("GetBrowserVersions", "COALESCE(sessions.browser_version, '')", "''", " AND sessions.browser = $7")
+	getOperatingSystems = breakdown("GetOperatingSystems", "COALESCE(sessions.os, '')", "''", "")
+	getOSVersions       = breakdown("GetOSVersions", "COALESCE(sessions.os_version, '')", "''", " AND sessions.os = $7")
+	getScreenTypes      = breakdown("GetScreenTypes", "COALESCE(sessions.screen_type, '')", "''", "")
+	getLanguages        = breakdown("GetLanguages", "COALESCE(sessions.language, '')", "''", "")
+)
+
+type GetBreakdownParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Sort    string
+	Limit   int64
+	Offset  int64
+	Filters store.Filters
+}
+
+type GetBreakdownRow struct {
+	Name          string
+	Country       string
+	Visitors      int64
+	Percentage    int64
+	Visits        int64
+	Pageviews     int64
+	BounceRate    int64
+	VisitDuration int64
+	CustomEvents  int64
+	Conversions   int64
+}
+
+func (q *Queries) GetCountries(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getCountries, arg)
+}
+
+func (q *Queries) GetRegions(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getRegions, arg)
+}
+
+func (q *Queries) GetSources(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getSources, arg)
+}
+
+func (q *Queries) GetReferrers(ctx context.Context, arg GetBreakdownParams, source string) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getReferrers, arg, source)
+}
+
+func (q *Queries) GetUTMSources(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getUTMSources, arg)
+}
+
+func (q *Queries) GetUTMMediums(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getUTMMediums, arg)
+}
+
+func (q *Queries) GetUTMCampaigns(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getUTMCampaigns, arg)
+}
+
+func (q *Queries) GetUTMTerms(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getUTMTerms, arg)
+}
+
+func (q *Queries) GetUTMContents(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getUTMContents, arg)
+}
+
+func (q *Queries) GetBrowsers(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getBrowsers, arg)
+}
+
+func (q *Queries) GetBrowserVersions(ctx context.Context, arg GetBreakdownParams, browser string) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getBrowserVersions, arg, browser)
+}
+
+func (q *Queries) GetOperatingSystems(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getOperatingSystems, arg)
+}
+
+func (q *Queries) GetOSVersions(ctx context.Context, arg GetBreakdownParams, os string) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getOSVersions, arg, os)
+}
+
+func (q *Queries) GetScreenTypes(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getScreenTypes, arg)
+}
+
+func (q *Queries) GetLanguages(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+	return q.getBreakdown(ctx, getLanguages, arg)
+}
+
+// getBreakdown runs one of the breakdown queries, extra holds the arguments
+// of its condition.
+func (q *Queries) getBreakdown(ctx context.Context, query string, arg GetBreakdownParams, extra ...interface{}) ([]GetBreakdownRow, error) {
+	query, args := withFilters(query, arg.Filters, append([]interface{}{
+		arg.SiteID,
+		arg.From,
+		arg.To,
+		arg.Sort,
+		arg.Limit,
+		arg.Offset,
+	}, extra...)...)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBreakdownRow
+	for rows.Next() {
+		var i GetBreakdownRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Country,
+			&i.Visitors,
+			&i.Percentage,
+			&i.Visits,
+			&i.Pageviews,
+			&i.BounceRate,
+			&i.VisitDuration,
+			&i.CustomEvents,
+			&i.Conversions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getLinks is shared by the link reports, which split the events named by
+// the first extra argument by their url prop. The %s are the name, the
+// tables joined for it and a condition on the events.
+const getLinks = `-- name: %s :many
+WITH e AS (
+	SELECT
+		%s AS name,
+		events.session_id
+	FROM
+		events
+		JOIN visits ON visits.id = events.visit_id
+		JOIN props ON props.event_id = events.id AND props.key = 'url'%s
+	WHERE
+		visits.site_id = $1 AND visits.started_at BETWEEN $2 AND $3
+		AND events.event_name = $7%s AND /* filters */
+)
+SELECT * FROM (
+	SELECT
+		name,
+		COUNT(DISTINCT session_id) AS visitors,
+		COUNT(DISTINCT session_id) * 100 / (SELECT COUNT(DISTINCT session_id) FROM e) AS percentage,
+		COUNT(*) AS events
+	FROM
+		e
+	GROUP BY
+		name
+) AS t
+ORDER BY
+	CASE $4
+		WHEN 'events' THEN events
+		ELSE visitors
+	END DESC,
+	name ASC
+LIMIT $5 OFFSET $6
+`
+
+var (
+	getOutboundLinks       = fmt.Sprintf(getLinks, "GetOutboundLinks", "props.value", "", "")
+	getFileDownloads       = fmt.Sprintf(getLinks, "GetFileDownloads", "props.value", "", "")
+	getBrokenLinks         = fmt.Sprintf(getLinks, "GetBrokenLinks", "props.value", "", "")
+	getBrokenLinkReferrers = fmt.Sprintf(getLinks, "GetBrokenLinkReferrers", "COALESCE(referrers.value, '')", "\n\t\tLEFT JOIN props AS referrers ON referrers.event_id = events.id AND referrers.key = 'referrer'", " AND props.value = $8")
+)
+
+type GetLinksParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Sort    string
+	Limit   int64
+	Offset  int64
+	Filters store.Filters
+}
+
+type GetLinksRow struct {
+	Name       string
+	Visitors   int64
+	Percentage int64
+	Events     int64
+}
+
+func (q *Queries) GetOutboundLinks(ctx context.Context, arg GetLinksParams) ([]GetLinksRow, error) {
+	return q.getLinks(ctx, getOutboundLinks, arg, event.OutboundLinkEvent)
+}
+
+func (q *Queries) GetFileDownloads(ctx context.Context, arg GetLinksParams) ([]GetLinksRow, error) {
+	return q.getLinks(ctx, getFileDownloads, arg, event.FileDownloadEvent)
+}
+
+func (q *Queries) GetBrokenLinks(ctx context.Context, arg GetLinksParams) ([]GetLinksRow, error) {
+	return q.getLinks(ctx, getBrokenLinks, arg, event.NotFoundEvent)
+}
+
+func (q *Queries) GetBrokenLinkReferrers(ctx context.Context, arg GetLinksParams, url string) ([]GetLinksRow, error) {
+	return q.getLinks(ctx, getBrokenLinkReferrers, arg, event.NotFoundEvent, url)
+}
+
+func (q *Queries) getLinks(ctx context.Context, query string, arg GetLinksParams, extra ...interface{}) ([]GetLinksRow, error) {
+	query, args := withFilters(query, arg.Filters, append([]interface{}{
+		arg.SiteID,
+		arg.From,
+		arg.To,
+		arg.Sort,
+		arg.Limit,
+		arg.Offset,
+	}, extra...)...)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLinksRow
+	for rows.Next() {
+		var i GetLinksRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Visitors,
+			&i.Percentage,
+			&i.Events,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// goalMatch is the condition that an event reaches a goal.
+const goalMatch = `(
+		(goals.event_name <> '' AND events.event_name = goals.event_name)
+		OR (goals.page_path <> '' AND events.event_name = 'pageview' AND {{path "events.url"}} LIKE
+			replace(replace(replace(replace(goals.page_path, '\', '\\'), '%', '\%'), '_', '\_'), '*', '%') ESCAPE '\')
+	)`
+
+// getGoalConversions counts the events of each goal in the matching visits, a
+// page path is turned into a LIKE pattern the same way as a page filter.
+var getGoalConversions = fmt.Sprintf(`-- name: GetGoalConversions :many
+SELECT
+	goals.id,
+	COUNT(DISTINCT CASE WHEN events.id IS NOT NULL THEN visits.session_id END) AS unique_conversions,
+	COUNT(events.id) AS total_conversions
+FROM
+	goals
+	LEFT JOIN visits ON visits.site_id = goals.site_id
+		AND visits.started_at BETWEEN $2 AND $3
+		AND /* filters */
+	LEFT JOIN events ON events.visit_id = visits.id AND %s
+WHERE
+	goals.site_id = $1
+GROUP BY
+	goals.id
+ORDER BY
+	goals.id ASC
+`, goalMatch)
+
+type GetGoalConversionsParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Filters store.Filters
+}
+
+type GetGoalConversionsRow struct {
+	GoalID            int64
+	UniqueConversions int64
+	TotalConversions  int64
+}
+
+func (q *Queries) GetGoalConversions(ctx context.Context, arg GetGoalConversionsParams) ([]GetGoalConversionsRow, error) {
+	query, args := withFilters(getGoalConversions, arg.Filters,
+		arg.SiteID,
+		arg.From,
+		arg.To,
+	)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetGoalConversionsRow
+	for rows.Next() {
+		var i GetGoalConversionsRow
+		if err := rows.Scan(
+			&i.GoalID,
+			&i.UniqueConversions,
+			&i.TotalConversions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getRevenue sums the revenue of the events in the matching visits for each
+// name and currency, the %s are the name, the tables joined to name the
+// events and a condition on them.
+const getRevenue = `-- name: %s :many
+SELECT
+	%s AS name,
+	events.revenue_currency,
+	CAST(SUM(events.revenue_amount) AS BIGINT) AS amount,
+	COUNT(*) AS conversions
+FROM
+	events
+	JOIN visits ON visits.id = events.visit_id%s
+WHERE
+	visits.site_id = $1
+	AND visits.started_at BETWEEN $2 AND $3
+	AND events.revenue_currency IS NOT NULL%s
+	AND /* filters */
+GROUP BY
+	1, events.revenue_currency
+ORDER BY
+	1 ASC, events.revenue_currency ASC
+`
+
+var (
+	getTotalRevenue      = fmt.Sprintf(getRevenue, "GetTotalRevenue", "CAST('' AS TEXT)", "", "")
+	getRevenueByGoal     = fmt.Sprintf(getRevenue, "GetRevenueByGoal", "goals.name", "\n\tJOIN goals ON goals.site_id = visits.site_id AND "+goalMatch, "")
+	getRevenueBySource   = fmt.Sprintf(getRevenue, "GetRevenueBySource", "COALESCE(visits.referrer_source, '')", "", "")
+	getRevenueByCampaign = fmt.Sprintf(getRevenue, "GetRevenueByCampaign", "visits.utm_campaign", "", " AND visits.utm_campaign != ''")
+)
+
+type GetRevenueParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Filters store.Filters
+}
+
+type GetRevenueRow struct {
+	Name        string
+	Currency    string
+	Amount      int64
+	Conversions int64
+}
+
+func (q *Queries) GetTotalRevenue(ctx context.Context, arg GetRevenueParams) ([]GetRevenueRow, error) {
+	return q.getRevenue(ctx, getTotalRevenue, arg)
+}
+
+func (q *Queries) GetRevenueByGoal(ctx context.Context, arg GetRevenueParams) ([]GetRevenueRow, error) {
+	return q.getRevenue(ctx, getRevenueByGoal, arg)
+}
+
+func (q *Queries) GetRevenueBySource(ctx context.Context, arg GetRevenueParams) ([]GetRevenueRow, error) {
+	return q.getRevenue(ctx, getRevenueBySource, arg)
+}
+
+func (q *Queries) GetRevenueByCampaign(ctx context.Context, arg GetRevenueParams) ([]GetRevenueRow, error) {
+	return q.getRevenue(ctx, getRevenueByCampaign, arg)
+}
+
+func (q *Queries) getRevenue(ctx context.Context, query string, arg GetRevenueParams) ([]GetRevenueRow, error) {
+	query, args := withFilters(query, arg.Filters,
+		arg.SiteID,
+		arg.From,
+		arg.To,
+	)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRevenueRow
+	for rows.Next() {
+		var i GetRevenueRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Currency,
+			&i.Amount,
+			&i.Conversions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getFunnelEvents lists the events that reach any step of a funnel in the
+// order the steps are counted in. The first %s is whether the event reaches
+// each step and the second is whether it reaches any.
+const getFunnelEvents = `-- name: GetFunnelEvents :many
+SELECT
+	events.session_id,
+	events.created_at,
+	%s
+FROM
+	events
+	JOIN visits ON visits.id = events.visit_id
+WHERE
+	events.site_id = $1 AND events.created_at BETWEEN $2 AND $3 AND (%s) AND /* filters */
+ORDER BY
+	events.session_id, events.created_at, events.id
+`
+
+type GetFunnelEventsParams struct {
+	SiteID  int64
+	From    time.Time
+	To      time.Time
+	Steps   []store.FunnelStep
+	Filters store.Filters
+}
+
+type GetFunnelEventsRow struct {
+	SessionID string
+	CreatedAt time.Time
+	Steps     []bool
+}
+
+func (q *Queries) GetFunnelEvents(ctx context.Context, arg GetFunnelEventsParams) ([]GetFunnelEventsRow, error) {
+	args := []interface{}{arg.SiteID, arg.From, arg.To}
+	matches := make([]string, len(arg.Steps))
+	for i, step := range arg.Steps {
+		match, stepArgs := step.Match(dialect, len(args)+1)
+		matches[i] = match
+		args = append(args, stepArgs...)
+	}
+
+	columns := make([]string, len(matches))
+	for i, match := range matches {
+		columns[i] = fmt.Sprintf("%s AS step_%d", match, i+1)
+	}
+
+	query, args := withFilters(fmt.Sprintf(getFunnelEvents, strings.Join(columns, ",\n\t"), strings.Join(matches, " OR ")), arg.Filters, args...)
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFunnelEventsRow
+	for rows.Next() {
+		i := GetFunnelEventsRow{Steps: make([]bool, len(arg.Steps))}
+		dest := []interface{}{&i.SessionID, &i.CreatedAt}
+		for k := range i.Steps {
+			dest = append(dest, &i.Steps[k])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getCurrent counts the sessions with an event since a time for each name,
+// the %s are the name and a condition on the events.
+const getCurrent = `-- name: %s :many
+SELECT
+	%s AS name,
+	COUNT(DISTINCT events.session_id) AS visitors
+FROM
+	events
+	JOIN visits ON visits.id = events.visit_id
+WHERE
+	events.site_id = $1
+	AND events.created_at >= $2%s
+GROUP BY
+	1
+ORDER BY
+	visitors DESC,
+	name ASC
+LIMIT $3
+`
+
+var (
+	getCurrentPages   = fmt.Sprintf(getCurrent, "GetCurrentPages", dialect.Path("events.url"), " AND events.event_name = 'pageview'")
+	getCurrentSources = fmt.Sprintf(getCurrent, "GetCurrentSources", "COALESCE(visits.referrer_source, '')", "")
+)
+
+type GetCurrentParams struct {
+	SiteID int64
+	Since  time.Time
+	Limit  int64
+}
+
+type GetCurrentRow struct {
+	Name     string
+	Visitors int64
+}
+
+func (q *Queries) GetCurrentPages(ctx context.Context, arg GetCurrentParams) ([]GetCurrentRow, error) {
+	return q.getCurrent(ctx, getCurrentPages, arg)
+}
+
+func (q *Queries) GetCurrentSources(ctx context.Context, arg GetCurrentParams) ([]GetCurrentRow, error) {
+	return q.getCurrent(ctx, getCurrentSources, arg)
+}
+
+func (q *Queries) getCurrent(ctx context.Context, query string, arg GetCurrentParams) ([]GetCurrentRow, error) {
+	rows, err := q.db.QueryContext(ctx, query, arg.SiteID, arg.Since, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCurrentRow
+	for rows.Next() {
+		var i GetCurrentRow
+		if err := rows.Scan(&i.Name, &i.Visitors); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
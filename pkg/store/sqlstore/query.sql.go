@@ -0,0 +1,962 @@
+package sqlstore
+
+// The queries below started out generated by sqlc and are now written by
+// hand, they use the template actions of Dialect, which sqlc cannot parse.
+// Queries that are put together at run time are in query.go.
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const clearSalts = `-- name: ClearSalts :exec
+UPDATE salts SET value = {{zeroBytes "value"}}
+WHERE day < $1
+`
+
+func (q *Queries) ClearSalts(ctx context.Context, day string) error {
+	_, err := q.db.ExecContext(ctx, clearSalts, day)
+	return err
+}
+
+const createEvent = `-- name: CreateEvent :one
+INSERT INTO events (site_id, session_id, event_name, url, referrer, utm_source, utm_medium, utm_campaign, utm_term, utm_content, bot_reason, visit_id, revenue_amount, revenue_currency, engagement_time, scroll_depth, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id
+`
+
+type CreateEventParams struct {
+	SiteID          int64
+	SessionID       string
+	EventName       string
+	Url             string
+	Referrer        sql.NullString
+	UtmSource       sql.NullString
+	UtmMedium       sql.NullString
+	UtmCampaign     sql.NullString
+	UtmTerm         sql.NullString
+	UtmContent      sql.NullString
+	BotReason       sql.NullString
+	VisitID         sql.NullInt64
+	RevenueAmount   sql.NullInt64
+	RevenueCurrency sql.NullString
+	EngagementTime  sql.NullInt64
+	ScrollDepth     sql.NullInt64
+	CreatedAt       time.Time
+}
+
+func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, createEvent,
+		arg.SiteID,
+		arg.SessionID,
+		arg.EventName,
+		arg.Url,
+		arg.Referrer,
+		arg.UtmSource,
+		arg.UtmMedium,
+		arg.UtmCampaign,
+		arg.UtmTerm,
+		arg.UtmContent,
+		arg.BotReason,
+		arg.VisitID,
+		arg.RevenueAmount,
+		arg.RevenueCurrency,
+		arg.EngagementTime,
+		arg.ScrollDepth,
+		arg.CreatedAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createFunnel = `-- name: CreateFunnel :one
+INSERT INTO funnels (site_id, name, steps, window_seconds, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id, site_id, name, steps, window_seconds, created_at
+`
+
+type CreateFunnelParams struct {
+	SiteID        int64
+	Name          string
+	Steps         string
+	WindowSeconds int64
+	CreatedAt     time.Time
+}
+
+func (q *Queries) CreateFunnel(ctx context.Context, arg CreateFunnelParams) (Funnel, error) {
+	row := q.db.QueryRowContext(ctx, createFunnel,
+		arg.SiteID,
+		arg.Name,
+		arg.Steps,
+		arg.WindowSeconds,
+		arg.CreatedAt,
+	)
+	var i Funnel
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Name,
+		&i.Steps,
+		&i.WindowSeconds,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createGoal = `-- name: CreateGoal :one
+INSERT INTO goals (site_id, name, event_name, page_path, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id, site_id, name, event_name, page_path, created_at
+`
+
+type CreateGoalParams struct {
+	SiteID    int64
+	Name      string
+	EventName string
+	PagePath  string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateGoal(ctx context.Context, arg CreateGoalParams) (Goal, error) {
+	row := q.db.QueryRowContext(ctx, createGoal,
+		arg.SiteID,
+		arg.Name,
+		arg.EventName,
+		arg.PagePath,
+		arg.CreatedAt,
+	)
+	var i Goal
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Name,
+		&i.EventName,
+		&i.PagePath,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createProp = `-- name: CreateProp :exec
+INSERT INTO props (event_id, key, value, created_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreatePropParams struct {
+	EventID   int64
+	Key       string
+	Value     string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateProp(ctx context.Context, arg CreatePropParams) error {
+	_, err := q.db.ExecContext(ctx, createProp,
+		arg.EventID,
+		arg.Key,
+		arg.Value,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createRevenue = `-- name: CreateRevenue :exec
+INSERT INTO revenues (event_id, key, value, created_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateRevenueParams struct {
+	EventID   int64
+	Key       string
+	Value     string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateRevenue(ctx context.Context, arg CreateRevenueParams) error {
+	_, err := q.db.ExecContext(ctx, createRevenue,
+		arg.EventID,
+		arg.Key,
+		arg.Value,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createSalt = `-- name: CreateSalt :exec
+INSERT INTO salts (day, value, created_at)
+VALUES ($1, $2, $3) ON CONFLICT(day) DO NOTHING
+`
+
+type CreateSaltParams struct {
+	Day       string
+	Value     []byte
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateSalt(ctx context.Context, arg CreateSaltParams) error {
+	_, err := q.db.ExecContext(ctx, createSalt, arg.Day, arg.Value, arg.CreatedAt)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (id, site_id, language, country, region, city, browser, browser_version, os, os_version, screen_type, visitor_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+ON CONFLICT(id) DO UPDATE SET visitor_id = excluded.visitor_id
+WHERE sessions.visitor_id IS NULL AND excluded.visitor_id IS NOT NULL
+`
+
+type CreateSessionParams struct {
+	ID             string
+	SiteID         int64
+	Language       sql.NullString
+	Country        sql.NullString
+	Region         sql.NullString
+	City           sql.NullString
+	Browser        sql.NullString
+	BrowserVersion sql.NullString
+	Os             sql.NullString
+	OsVersion      sql.NullString
+	ScreenType     sql.NullString
+	VisitorID      sql.NullString
+	CreatedAt      time.Time
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession,
+		arg.ID,
+		arg.SiteID,
+		arg.Language,
+		arg.Country,
+		arg.Region,
+		arg.City,
+		arg.Browser,
+		arg.BrowserVersion,
+		arg.Os,
+		arg.OsVersion,
+		arg.ScreenType,
+		arg.VisitorID,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createSite = `-- name: CreateSite :one
+INSERT INTO sites (domain, created_at)
+VALUES ($1, $2) RETURNING id, domain, privacy, created_at
+`
+
+type CreateSiteParams struct {
+	Domain    string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateSite(ctx context.Context, arg CreateSiteParams) (Site, error) {
+	row := q.db.QueryRowContext(ctx, createSite, arg.Domain, arg.CreatedAt)
+	var i Site
+	err := row.Scan(&i.ID, &i.Domain, &i.Privacy, &i.CreatedAt)
+	return i, err
+}
+
+const createVisit = `-- name: CreateVisit :one
+INSERT INTO visits (site_id, session_id, started_at, ended_at, entry_url, exit_url, pageviews, events, is_bounce, referrer, utm_source, utm_medium, utm_campaign, utm_term, utm_content, referrer_source)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id
+`
+
+type CreateVisitParams struct {
+	SiteID         int64
+	SessionID      string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	EntryUrl       string
+	ExitUrl        string
+	Pageviews      int64
+	Events         int64
+	IsBounce       bool
+	Referrer       sql.NullString
+	UtmSource      sql.NullString
+	UtmMedium      sql.NullString
+	UtmCampaign    sql.NullString
+	UtmTerm        sql.NullString
+	UtmContent     sql.NullString
+	ReferrerSource sql.NullString
+}
+
+func (q *Queries) CreateVisit(ctx context.Context, arg CreateVisitParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, createVisit,
+		arg.SiteID,
+		arg.SessionID,
+		arg.StartedAt,
+		arg.EndedAt,
+		arg.EntryUrl,
+		arg.ExitUrl,
+		arg.Pageviews,
+		arg.Events,
+		arg.IsBounce,
+		arg.Referrer,
+		arg.UtmSource,
+		arg.UtmMedium,
+		arg.UtmCampaign,
+		arg.UtmTerm,
+		arg.UtmContent,
+		arg.ReferrerSource,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteFunnel = `-- name: DeleteFunnel :execrows
+DELETE FROM funnels
+WHERE id = $1 AND site_id = $2
+`
+
+type DeleteFunnelParams struct {
+	ID     int64
+	SiteID int64
+}
+
+func (q *Queries) DeleteFunnel(ctx context.Context, arg DeleteFunnelParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFunnel, arg.ID, arg.SiteID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteGoal = `-- name: DeleteGoal :execrows
+DELETE FROM goals
+WHERE id = $1 AND site_id = $2
+`
+
+type DeleteGoalParams struct {
+	ID     int64
+	SiteID int64
+}
+
+func (q *Queries) DeleteGoal(ctx context.Context, arg DeleteGoalParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGoal, arg.ID, arg.SiteID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSalts = `-- name: DeleteSalts :exec
+DELETE FROM salts
+WHERE day < $1
+`
+
+func (q *Queries) DeleteSalts(ctx context.Context, day string) error {
+	_, err := q.db.ExecContext(ctx, deleteSalts, day)
+	return err
+}
+
+const getCurrentVisitors = `-- name: GetCurrentVisitors :one
+SELECT COUNT(DISTINCT session_id) FROM events
+WHERE site_id = $1 AND created_at >= $2 AND visit_id IS NOT NULL
+`
+
+type GetCurrentVisitorsParams struct {
+	SiteID int64
+	Since  time.Time
+}
+
+func (q *Queries) GetCurrentVisitors(ctx context.Context, arg GetCurrentVisitorsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getCurrentVisitors, arg.SiteID, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getFilteredCounts = `-- name: GetFilteredCounts :many
+SELECT reason, CAST(SUM(count) AS BIGINT) AS count FROM filtered_events
+WHERE site_id = $1 AND day BETWEEN $2 AND $3
+GROUP BY reason
+ORDER BY count DESC, reason ASC
+`
+
+type GetFilteredCountsParams struct {
+	SiteID int64
+	From   string
+	To     string
+}
+
+type GetFilteredCountsRow struct {
+	Reason string
+	Count  int64
+}
+
+func (q *Queries) GetFilteredCounts(ctx context.Context, arg GetFilteredCountsParams) ([]GetFilteredCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFilteredCounts, arg.SiteID, arg.From, arg.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFilteredCountsRow
+	for rows.Next() {
+		var i GetFilteredCountsRow
+		if err := rows.Scan(&i.Reason, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFunnel = `-- name: GetFunnel :one
+SELECT id, site_id, name, steps, window_seconds, created_at FROM funnels
+WHERE id = $1 AND site_id = $2 LIMIT 1
+`
+
+type GetFunnelParams struct {
+	ID     int64
+	SiteID int64
+}
+
+func (q *Queries) GetFunnel(ctx context.Context, arg GetFunnelParams) (Funnel, error) {
+	row := q.db.QueryRowContext(ctx, getFunnel, arg.ID, arg.SiteID)
+	var i Funnel
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Name,
+		&i.Steps,
+		&i.WindowSeconds,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastVisit = `-- name: GetLastVisit :one
+SELECT id, site_id, session_id, started_at, ended_at, entry_url, exit_url, pageviews, events, is_bounce, referrer, utm_source, utm_medium, utm_campaign, utm_term, utm_content, referrer_source, engagement_time FROM visits
+WHERE site_id = $1 AND session_id = $2
+ORDER BY ended_at DESC LIMIT 1
+`
+
+type GetLastVisitParams struct {
+	SiteID    int64
+	SessionID string
+}
+
+func (q *Queries) GetLastVisit(ctx context.Context, arg GetLastVisitParams) (Visit, error) {
+	row := q.db.QueryRowContext(ctx, getLastVisit, arg.SiteID, arg.SessionID)
+	var i Visit
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.SessionID,
+		&i.StartedAt,
+		&i.EndedAt,
+		&i.EntryUrl,
+		&i.ExitUrl,
+		&i.Pageviews,
+		&i.Events,
+		&i.IsBounce,
+		&i.Referrer,
+		&i.UtmSource,
+		&i.UtmMedium,
+		&i.UtmCampaign,
+		&i.UtmTerm,
+		&i.UtmContent,
+		&i.ReferrerSource,
+		&i.EngagementTime,
+	)
+	return i, err
+}
+
+const getPropsByEventName = `-- name: GetPropsByEventName :many
+SELECT props.id, props.event_id, props.key, props.value, props.created_at FROM props
+JOIN events ON events.id = props.event_id
+WHERE events.site_id = $1 AND events.event_name = $2
+ORDER BY props.event_id DESC, props.id ASC
+`
+
+type GetPropsByEventNameParams struct {
+	SiteID    int64
+	EventName string
+}
+
+func (q *Queries) GetPropsByEventName(ctx context.Context, arg GetPropsByEventNameParams) ([]Prop, error) {
+	rows, err := q.db.QueryContext(ctx, getPropsByEventName, arg.SiteID, arg.EventName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Prop
+	for rows.Next() {
+		var i Prop
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.Key,
+			&i.Value,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRevenuesByEventName = `-- name: GetRevenuesByEventName :many
+SELECT revenues.id, revenues.event_id, revenues.key, revenues.value, revenues.created_at FROM revenues
+JOIN events ON events.id = revenues.event_id
+WHERE events.site_id = $1 AND events.event_name = $2
+ORDER BY revenues.event_id DESC, revenues.id ASC
+`
+
+type GetRevenuesByEventNameParams struct {
+	SiteID    int64
+	EventName string
+}
+
+func (q *Queries) GetRevenuesByEventName(ctx context.Context, arg GetRevenuesByEventNameParams) ([]Revenue, error) {
+	rows, err := q.db.QueryContext(ctx, getRevenuesByEventName, arg.SiteID, arg.EventName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Revenue
+	for rows.Next() {
+		var i Revenue
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.Key,
+			&i.Value,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSalt = `-- name: GetSalt :one
+SELECT day, value, created_at FROM salts
+WHERE day = $1 LIMIT 1
+`
+
+func (q *Queries) GetSalt(ctx context.Context, day string) (Salt, error) {
+	row := q.db.QueryRowContext(ctx, getSalt, day)
+	var i Salt
+	err := row.Scan(&i.Day, &i.Value, &i.CreatedAt)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, site_id, language, country, region, city, browser, browser_version, os, os_version, screen_type, visitor_id, created_at FROM sessions
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Language,
+		&i.Country,
+		&i.Region,
+		&i.City,
+		&i.Browser,
+		&i.BrowserVersion,
+		&i.Os,
+		&i.OsVersion,
+		&i.ScreenType,
+		&i.VisitorID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSiteByDomain = `-- name: GetSiteByDomain :one
+SELECT id, domain, privacy, created_at FROM sites
+WHERE domain = $1 LIMIT 1
+`
+
+func (q *Queries) GetSiteByDomain(ctx context.Context, domain string) (Site, error) {
+	row := q.db.QueryRowContext(ctx, getSiteByDomain, domain)
+	var i Site
+	err := row.Scan(&i.ID, &i.Domain, &i.Privacy, &i.CreatedAt)
+	return i, err
+}
+
+const incrementFiltered = `-- name: IncrementFiltered :exec
+INSERT INTO filtered_events (site_id, reason, day, count)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT(site_id, reason, day) DO UPDATE SET count = filtered_events.count + 1
+`
+
+type IncrementFilteredParams struct {
+	SiteID int64
+	Reason string
+	Day    string
+}
+
+func (q *Queries) IncrementFiltered(ctx context.Context, arg IncrementFilteredParams) error {
+	_, err := q.db.ExecContext(ctx, incrementFiltered, arg.SiteID, arg.Reason, arg.Day)
+	return err
+}
+
+const listEventsWithoutVisit = `-- name: ListEventsWithoutVisit :many
+SELECT events.id, events.site_id, COALESCE(sites.domain, '') AS domain, events.session_id, events.event_name, events.url, events.referrer, events.utm_source, events.utm_medium, events.utm_campaign, events.utm_term, events.utm_content, events.created_at FROM events
+LEFT JOIN sites ON sites.id = events.site_id
+WHERE events.visit_id IS NULL AND events.bot_reason IS NULL
+ORDER BY events.created_at ASC, events.id ASC LIMIT $1
+`
+
+type ListEventsWithoutVisitRow struct {
+	ID          int64
+	SiteID      int64
+	Domain      string
+	SessionID   string
+	EventName   string
+	Url         string
+	Referrer    sql.NullString
+	UtmSource   sql.NullString
+	UtmMedium   sql.NullString
+	UtmCampaign sql.NullString
+	UtmTerm     sql.NullString
+	UtmContent  sql.NullString
+	CreatedAt   time.Time
+}
+
+func (q *Queries) ListEventsWithoutVisit(ctx context.Context, limit int64) ([]ListEventsWithoutVisitRow, error) {
+	rows, err := q.db.QueryContext(ctx, listEventsWithoutVisit, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListEventsWithoutVisitRow
+	for rows.Next() {
+		var i ListEventsWithoutVisitRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SiteID,
+			&i.Domain,
+			&i.SessionID,
+			&i.EventName,
+			&i.Url,
+			&i.Referrer,
+			&i.UtmSource,
+			&i.UtmMedium,
+			&i.UtmCampaign,
+			&i.UtmTerm,
+			&i.UtmContent,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFunnels = `-- name: ListFunnels :many
+SELECT id, site_id, name, steps, window_seconds, created_at FROM funnels
+WHERE site_id = $1
+ORDER BY id ASC
+`
+
+func (q *Queries) ListFunnels(ctx context.Context, siteID int64) ([]Funnel, error) {
+	rows, err := q.db.QueryContext(ctx, listFunnels, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Funnel
+	for rows.Next() {
+		var i Funnel
+		if err := rows.Scan(
+			&i.ID,
+			&i.SiteID,
+			&i.Name,
+			&i.Steps,
+			&i.WindowSeconds,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGoals = `-- name: ListGoals :many
+SELECT id, site_id, name, event_name, page_path, created_at FROM goals
+WHERE site_id = $1
+ORDER BY id ASC
+`
+
+func (q *Queries) ListGoals(ctx context.Context, siteID int64) ([]Goal, error) {
+	rows, err := q.db.QueryContext(ctx, listGoals, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Goal
+	for rows.Next() {
+		var i Goal
+		if err := rows.Scan(
+			&i.ID,
+			&i.SiteID,
+			&i.Name,
+			&i.EventName,
+			&i.PagePath,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSites = `-- name: ListSites :many
+SELECT id, domain, privacy, created_at FROM sites
+ORDER BY domain ASC
+`
+
+func (q *Queries) ListSites(ctx context.Context) ([]Site, error) {
+	rows, err := q.db.QueryContext(ctx, listSites)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Site
+	for rows.Next() {
+		var i Site
+		if err := rows.Scan(&i.ID, &i.Domain, &i.Privacy, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVisitsWithoutSource = `-- name: ListVisitsWithoutSource :many
+SELECT visits.id, visits.referrer, COALESCE(sites.domain, '') AS domain FROM visits
+LEFT JOIN sites ON sites.id = visits.site_id
+WHERE visits.referrer_source IS NULL
+ORDER BY visits.id ASC LIMIT $1
+`
+
+type ListVisitsWithoutSourceRow struct {
+	ID       int64
+	Referrer sql.NullString
+	Domain   string
+}
+
+func (q *Queries) ListVisitsWithoutSource(ctx context.Context, limit int64) ([]ListVisitsWithoutSourceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listVisitsWithoutSource, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListVisitsWithoutSourceRow
+	for rows.Next() {
+		var i ListVisitsWithoutSourceRow
+		if err := rows.Scan(&i.ID, &i.Referrer, &i.Domain); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setEventVisit = `-- name: SetEventVisit :exec
+UPDATE events SET visit_id = $2
+WHERE id = $1
+`
+
+type SetEventVisitParams struct {
+	ID      int64
+	VisitID sql.NullInt64
+}
+
+func (q *Queries) SetEventVisit(ctx context.Context, arg SetEventVisitParams) error {
+	_, err := q.db.ExecContext(ctx, setEventVisit, arg.ID, arg.VisitID)
+	return err
+}
+
+const setVisitReferrer = `-- name: SetVisitReferrer :exec
+UPDATE visits SET referrer = $2, referrer_source = $3
+WHERE id = $1
+`
+
+type SetVisitReferrerParams struct {
+	ID             int64
+	Referrer       sql.NullString
+	ReferrerSource sql.NullString
+}
+
+func (q *Queries) SetVisitReferrer(ctx context.Context, arg SetVisitReferrerParams) error {
+	_, err := q.db.ExecContext(ctx, setVisitReferrer, arg.ID, arg.Referrer, arg.ReferrerSource)
+	return err
+}
+
+const updateFunnel = `-- name: UpdateFunnel :one
+UPDATE funnels SET name = $1, steps = $2, window_seconds = $3
+WHERE id = $4 AND site_id = $5
+RETURNING id, site_id, name, steps, window_seconds, created_at
+`
+
+type UpdateFunnelParams struct {
+	Name          string
+	Steps         string
+	WindowSeconds int64
+	ID            int64
+	SiteID        int64
+}
+
+func (q *Queries) UpdateFunnel(ctx context.Context, arg UpdateFunnelParams) (Funnel, error) {
+	row := q.db.QueryRowContext(ctx, updateFunnel,
+		arg.Name,
+		arg.Steps,
+		arg.WindowSeconds,
+		arg.ID,
+		arg.SiteID,
+	)
+	var i Funnel
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Name,
+		&i.Steps,
+		&i.WindowSeconds,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateGoal = `-- name: UpdateGoal :one
+UPDATE goals SET name = $1, event_name = $2, page_path = $3
+WHERE id = $4 AND site_id = $5
+RETURNING id, site_id, name, event_name, page_path, created_at
+`
+
+type UpdateGoalParams struct {
+	Name      string
+	EventName string
+	PagePath  string
+	ID        int64
+	SiteID    int64
+}
+
+func (q *Queries) UpdateGoal(ctx context.Context, arg UpdateGoalParams) (Goal, error) {
+	row := q.db.QueryRowContext(ctx, updateGoal,
+		arg.Name,
+		arg.EventName,
+		arg.PagePath,
+		arg.ID,
+		arg.SiteID,
+	)
+	var i Goal
+	err := row.Scan(
+		&i.ID,
+		&i.SiteID,
+		&i.Name,
+		&i.EventName,
+		&i.PagePath,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateSitePrivacy = `-- name: UpdateSitePrivacy :one
+UPDATE sites SET privacy = $1
+WHERE domain = $2 RETURNING id, domain, privacy, created_at
+`
+
+type UpdateSitePrivacyParams struct {
+	Privacy string
+	Domain  string
+}
+
+func (q *Queries) UpdateSitePrivacy(ctx context.Context, arg UpdateSitePrivacyParams) (Site, error) {
+	row := q.db.QueryRowContext(ctx, updateSitePrivacy, arg.Privacy, arg.Domain)
+	var i Site
+	err := row.Scan(&i.ID, &i.Domain, &i.Privacy, &i.CreatedAt)
+	return i, err
+}
+
+const updateVisit = `-- name: UpdateVisit :exec
+UPDATE visits SET started_at = $2, ended_at = $3, entry_url = $4, exit_url = $5, pageviews = $6, events = $7, is_bounce = $8, engagement_time = $9
+WHERE id = $1
+`
+
+type UpdateVisitParams struct {
+	ID             int64
+	StartedAt      time.Time
+	EndedAt        time.Time
+	EntryUrl       string
+	ExitUrl        string
+	Pageviews      int64
+	Events         int64
+	IsBounce       bool
+	EngagementTime int64
+}
+
+func (q *Queries) UpdateVisit(ctx context.Context, arg UpdateVisitParams) error {
+	_, err := q.db.ExecContext(ctx, updateVisit,
+		arg.ID,
+		arg.StartedAt,
+		arg.EndedAt,
+		arg.EntryUrl,
+		arg.ExitUrl,
+		arg.Pageviews,
+		arg.Events,
+		arg.IsBounce,
+		arg.EngagementTime,
+	)
+	return err
+}
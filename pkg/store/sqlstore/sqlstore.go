@@ -0,0 +1,1387 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// Store is a store.DBClient on a SQL database, the backends open the
+// database and create its schema.
+type Store struct {
+	ctx context.Context
+	db  *sql.DB
+	d   *Dialect
+	q   *Queries
+}
+
+// Open returns the store for db, whose queries are written in d.
+func Open(db *sql.DB, d *Dialect) (store.DBClient, error) {
+	s := &Store{
+		ctx: context.Background(),
+		db:  db,
+		d:   d,
+		q:   New(dialectDB{db, d}),
+	}
+
+	if err := s.backfillVisits(); err != nil {
+		return nil, err
+	}
+
+	if err := s.backfillSources(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// withTx returns the queries run in tx.
+func (s *Store) withTx(tx *sql.Tx) *Queries {
+	return New(dialectDB{tx, s.d})
+}
+
+func (s *Store) CreateSite(domain string) (*store.Site, error) {
+	site, err := s.q.CreateSite(s.ctx, CreateSiteParams{
+		Domain:    event.NormalizeDomain(domain),
+		CreatedAt: time.Now().UTC(),
+	})
+	if s.d.IsUniqueViolation(err) {
+		return nil, store.ErrSiteExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toSite(site), nil
+}
+
+func (s *Store) GetSite(domain string) (*store.Site, error) {
+	site, err := s.q.GetSiteByDomain(s.ctx, event.NormalizeDomain(domain))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	return toSite(site), nil
+}
+
+func (s *Store) GetSites() ([]*store.Site, error) {
+	res, err := s.q.ListSites(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := make([]*store.Site, len(res))
+	for i, site := range res {
+		sites[i] = toSite(site)
+	}
+
+	return sites, nil
+}
+
+func (s *Store) SetSitePrivacy(domain string, privacy store.Privacy) (*store.Site, error) {
+	site, err := s.q.UpdateSitePrivacy(s.ctx, UpdateSitePrivacyParams{
+		Privacy: string(privacy),
+		Domain:  event.NormalizeDomain(domain),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	return toSite(site), nil
+}
+
+func toSite(site Site) *store.Site {
+	return &store.Site{
+		ID:        site.ID,
+		Domain:    site.Domain,
+		Privacy:   store.Privacy(site.Privacy),
+		CreatedAt: site.CreatedAt,
+	}
+}
+
+func (s *Store) siteID(domain string) (int64, error) {
+	site, err := s.GetSite(domain)
+	if err != nil {
+		return 0, err
+	}
+	return site.ID, nil
+}
+
+func (s *Store) InsertEvent(ev *event.WEvent) (int64, error) {
+	siteID, err := s.siteID(ev.Domain)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := s.insertEvent(s.withTx(tx), siteID, ev)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *Store) InsertSession(session *event.Session) error {
+	site, err := s.GetSite(session.Domain)
+	if err != nil {
+		return err
+	}
+
+	if err := s.resolveSession(s.q, session); err != nil {
+		return err
+	}
+
+	return s.insertSession(s.q, site.ID, site.Privacy, session)
+}
+
+func (s *Store) InsertRecords(records []*store.Record) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := s.withTx(tx)
+
+	// resolve each domain once per batch, records for unknown sites are skipped
+	sites := make(map[string]Site)
+	for _, r := range records {
+		site, ok := sites[r.Event.Domain]
+		if !ok {
+			var err error
+			site, err = q.GetSiteByDomain(s.ctx, r.Event.Domain)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			sites[r.Event.Domain] = site
+		}
+		siteID := site.ID
+		if siteID == 0 {
+			continue
+		}
+
+		if r.Event.BotReason != "" {
+			if err := q.IncrementFiltered(s.ctx, IncrementFilteredParams{
+				SiteID: siteID,
+				Reason: r.Event.BotReason,
+				Day:    r.Event.CreatedAt.UTC().Format(time.DateOnly),
+			}); err != nil {
+				return err
+			}
+		}
+		if r.Discard {
+			continue
+		}
+
+		if err := s.resolveSession(q, r.Session); err != nil {
+			return err
+		}
+		r.Event.SessionID = r.Session.SessionID
+
+		if err := s.insertSession(q, siteID, store.Privacy(site.Privacy), r.Session); err != nil {
+			return err
+		}
+
+		if _, err := s.insertEvent(q, siteID, r.Event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) insertEvent(q *Queries, siteID int64, ev *event.WEvent) (int64, error) {
+	// events from automated traffic are never part of a visit
+	var visitID sql.NullInt64
+	if ev.BotReason == "" {
+		id, err := s.trackVisit(q, siteID, ev)
+		if err != nil {
+			return 0, err
+		}
+		// engagement without a visit to add to is of no use
+		if id == 0 {
+			return 0, nil
+		}
+		visitID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	var (
+		amount   sql.NullInt64
+		currency sql.NullString
+	)
+	if ev.Money != nil {
+		amount = sql.NullInt64{Int64: ev.Money.Micros, Valid: true}
+		currency = sql.NullString{String: ev.Money.Currency, Valid: true}
+	}
+
+	var engagementTime, scrollDepth sql.NullInt64
+	if ev.Engagement != nil {
+		engagementTime = sql.NullInt64{Int64: ev.Engagement.Time.Milliseconds(), Valid: true}
+		scrollDepth = sql.NullInt64{Int64: int64(ev.Engagement.ScrollDepth), Valid: true}
+	}
+
+	var (
+		id  int64
+		err error
+	)
+	if ev.UTM == nil {
+		id, err = q.CreateEvent(s.ctx, CreateEventParams{
+			SiteID:          siteID,
+			SessionID:       ev.SessionID,
+			EventName:       ev.EventName,
+			Url:             ev.Url,
+			Referrer:        sql.NullString{String: ev.Referrer, Valid: ev.Referrer != ""},
+			UtmSource:       sql.NullString{Valid: false},
+			UtmMedium:       sql.NullString{Valid: false},
+			UtmCampaign:     sql.NullString{Valid: false},
+			UtmTerm:         sql.NullString{Valid: false},
+			UtmContent:      sql.NullString{Valid: false},
+			BotReason:       sql.NullString{String: ev.BotReason, Valid: ev.BotReason != ""},
+			VisitID:         visitID,
+			RevenueAmount:   amount,
+			RevenueCurrency: currency,
+			EngagementTime:  engagementTime,
+			ScrollDepth:     scrollDepth,
+			CreatedAt:       ev.CreatedAt,
+		})
+	} else {
+		id, err = q.CreateEvent(s.ctx, CreateEventParams{
+			SiteID:          siteID,
+			SessionID:       ev.SessionID,
+			EventName:       ev.EventName,
+			Url:             ev.Url,
+			Referrer:        sql.NullString{String: ev.Referrer, Valid: true},
+			UtmSource:       sql.NullString{String: ev.UTM.Source, Valid: true},
+			UtmMedium:       sql.NullString{String: ev.UTM.Medium, Valid: true},
+			UtmCampaign:     sql.NullString{String: ev.UTM.Campaign, Valid: true},
+			UtmTerm:         sql.NullString{String: ev.UTM.Term, Valid: true},
+			UtmContent:      sql.NullString{String: ev.UTM.Content, Valid: true},
+			BotReason:       sql.NullString{String: ev.BotReason, Valid: ev.BotReason != ""},
+			VisitID:         visitID,
+			RevenueAmount:   amount,
+			RevenueCurrency: currency,
+			EngagementTime:  engagementTime,
+			ScrollDepth:     scrollDepth,
+			CreatedAt:       ev.CreatedAt,
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for k, v := range ev.Props {
+		if err := q.CreateProp(s.ctx, CreatePropParams{
+			EventID:   id,
+			Key:       k,
+			Value:     store.FormatValue(v),
+			CreatedAt: ev.CreatedAt,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	for k, v := range ev.Revenue {
+		if err := q.CreateRevenue(s.ctx, CreateRevenueParams{
+			EventID:   id,
+			Key:       k,
+			Value:     store.FormatValue(v),
+			CreatedAt: ev.CreatedAt,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// trackVisit adds ev to the visit it continues, or starts a new one, and
+// returns the id of the visit.
+func (s *Store) trackVisit(q *Queries, siteID int64, ev *event.WEvent) (int64, error) {
+	last, err := q.GetLastVisit(s.ctx, GetLastVisitParams{
+		SiteID:    siteID,
+		SessionID: ev.SessionID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if err == nil {
+		v := toVisit(last)
+		if v.Continues(ev) {
+			v.Add(ev)
+			return v.ID, q.UpdateVisit(s.ctx, UpdateVisitParams{
+				ID:             v.ID,
+				StartedAt:      v.StartedAt,
+				EndedAt:        v.EndedAt,
+				EntryUrl:       v.EntryUrl,
+				ExitUrl:        v.ExitUrl,
+				Pageviews:      int64(v.Pageviews),
+				Events:         int64(v.Events),
+				IsBounce:       v.IsBounce(),
+				EngagementTime: v.EngagementTime.Milliseconds(),
+			})
+		}
+	}
+
+	// engagement never starts a visit
+	if ev.IsEngagement() {
+		return 0, nil
+	}
+
+	v := store.NewVisit(siteID, ev)
+	params := CreateVisitParams{
+		SiteID:    v.SiteID,
+		SessionID: v.SessionID,
+		StartedAt: v.StartedAt,
+		EndedAt:   v.EndedAt,
+		EntryUrl:  v.EntryUrl,
+		ExitUrl:   v.ExitUrl,
+		Pageviews: int64(v.Pageviews),
+		Events:    int64(v.Events),
+		IsBounce:  v.IsBounce(),
+		Referrer:  sql.NullString{String: v.Referrer, Valid: v.Referrer != ""},
+		// always set so backfillSources can tell which visits predate it
+		ReferrerSource: sql.NullString{String: v.ReferrerSource, Valid: true},
+	}
+	if v.UTM != nil {
+		params.UtmSource = sql.NullString{String: v.UTM.Source, Valid: true}
+		params.UtmMedium = sql.NullString{String: v.UTM.Medium, Valid: true}
+		params.UtmCampaign = sql.NullString{String: v.UTM.Campaign, Valid: true}
+		params.UtmTerm = sql.NullString{String: v.UTM.Term, Valid: true}
+		params.UtmContent = sql.NullString{String: v.UTM.Content, Valid: true}
+	}
+	return q.CreateVisit(s.ctx, params)
+}
+
+// backfillVisits builds the visits of events that were stored before visits
+// were tracked on ingest.
+func (s *Store) backfillVisits() error {
+	for {
+		tx, err := s.db.BeginTx(s.ctx, nil)
+		if err != nil {
+			return err
+		}
+		q := s.withTx(tx)
+
+		rows, err := q.ListEventsWithoutVisit(s.ctx, 1000)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		for _, r := range rows {
+			ev := &event.WEvent{
+				Domain:    r.Domain,
+				SessionID: r.SessionID,
+				EventName: r.EventName,
+				Url:       r.Url,
+				CreatedAt: r.CreatedAt,
+			}
+			ev.Referrer, ev.ReferrerSource = event.NormalizeReferrer(r.Referrer.String, r.Domain)
+			if r.UtmSource.Valid {
+				ev.UTM = &event.UTM{
+					Source:   r.UtmSource.String,
+					Medium:   r.UtmMedium.String,
+					Campaign: r.UtmCampaign.String,
+					Term:     r.UtmTerm.String,
+					Content:  r.UtmContent.String,
+				}
+			}
+
+			visitID, err := s.trackVisit(q, r.SiteID, ev)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := q.SetEventVisit(s.ctx, SetEventVisitParams{
+				ID:      r.ID,
+				VisitID: sql.NullInt64{Int64: visitID, Valid: true},
+			}); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if len(rows) < 1000 {
+			return nil
+		}
+	}
+}
+
+// backfillSources sets the referrer source of visits that were stored before
+// referrers were normalized.
+func (s *Store) backfillSources() error {
+	for {
+		rows, err := s.q.ListVisitsWithoutSource(s.ctx, 1000)
+		if err != nil {
+			return err
+		}
+
+		tx, err := s.db.BeginTx(s.ctx, nil)
+		if err != nil {
+			return err
+		}
+		q := s.withTx(tx)
+
+		for _, r := range rows {
+			referrer, source := event.NormalizeReferrer(r.Referrer.String, r.Domain)
+			if err := q.SetVisitReferrer(s.ctx, SetVisitReferrerParams{
+				ID:             r.ID,
+				Referrer:       sql.NullString{String: referrer, Valid: referrer != ""},
+				ReferrerSource: sql.NullString{String: source, Valid: true},
+			}); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if len(rows) < 1000 {
+			return nil
+		}
+	}
+}
+
+func toVisit(v Visit) *store.Visit {
+	visit := &store.Visit{
+		ID:             v.ID,
+		SiteID:         v.SiteID,
+		SessionID:      v.SessionID,
+		StartedAt:      v.StartedAt,
+		EndedAt:        v.EndedAt,
+		EntryUrl:       v.EntryUrl,
+		ExitUrl:        v.ExitUrl,
+		Pageviews:      int(v.Pageviews),
+		Events:         int(v.Events),
+		Referrer:       v.Referrer.String,
+		ReferrerSource: v.ReferrerSource.String,
+		EngagementTime: time.Duration(v.EngagementTime) * time.Millisecond,
+	}
+	if v.UtmSource.Valid {
+		visit.UTM = &event.UTM{
+			Source:   v.UtmSource.String,
+			Medium:   v.UtmMedium.String,
+			Campaign: v.UtmCampaign.String,
+			Term:     v.UtmTerm.String,
+			Content:  v.UtmContent.String,
+		}
+	}
+	return visit
+}
+
+// resolveSession keeps the id a visitor had under the previous salt when
+// they already have a session with it, so a visit can span a rotation.
+func (s *Store) resolveSession(q *Queries, session *event.Session) error {
+	if session.PreviousID == "" {
+		return nil
+	}
+
+	if _, err := q.GetSession(s.ctx, session.PreviousID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	session.SessionID = session.PreviousID
+	return nil
+}
+
+func (s *Store) insertSession(q *Queries, siteID int64, privacy store.Privacy, session *event.Session) error {
+	// the visitor id is only kept by sites that recognise returning visitors
+	var visitorID sql.NullString
+	if privacy == store.PrivacyReturning && session.VisitorID != "" {
+		visitorID = sql.NullString{String: session.VisitorID, Valid: true}
+	}
+
+	return q.CreateSession(s.ctx, CreateSessionParams{
+		ID:             session.SessionID,
+		SiteID:         siteID,
+		Language:       sql.NullString{String: session.Language, Valid: true},
+		Country:        sql.NullString{String: session.Country, Valid: true},
+		Region:         sql.NullString{String: session.Region, Valid: true},
+		City:           sql.NullString{String: session.City, Valid: true},
+		Browser:        sql.NullString{String: session.Browser, Valid: true},
+		BrowserVersion: sql.NullString{String: session.BrowserVersion, Valid: true},
+		Os:             sql.NullString{String: session.Os, Valid: true},
+		OsVersion:      sql.NullString{String: session.OsVersion, Valid: true},
+		ScreenType:     sql.NullString{String: string(session.ScreenType), Valid: true},
+		VisitorID:      visitorID,
+		CreatedAt:      session.CreatedAt,
+	})
+}
+
+func (s *Store) GetStats(site string, from time.Time, to time.Time, filters store.Filters) (*store.Stats, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &store.Stats{}
+
+	arg := GetStatsParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Filters: filters,
+	}
+	st, err := s.q.GetStats(s.ctx, arg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.PageViews.Valid {
+		stats.PageViews = int(st.PageViews.Int64)
+	}
+
+	if st.UniqueVisitors.Valid {
+		stats.Visitors = int(st.UniqueVisitors.Int64)
+	}
+
+	if st.Bounces.Valid {
+		stats.Bounces = int(st.Bounces.Int64)
+	}
+
+	if st.AverageSessionLength.Valid {
+		stats.AverageSessionLength = int(st.AverageSessionLength.Int64)
+	}
+
+	engagement, err := s.q.GetEngagement(s.ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	stats.TimeOnPage = int(engagement.TimeOnPage)
+	stats.ScrollDepth = int(engagement.ScrollDepth)
+
+	return stats, nil
+}
+
+func (s *Store) GetGraph(site string, from time.Time, to time.Time, interval store.Interval, loc *time.Location, metrics []store.Metric, filters store.Filters) (*store.GraphStats, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := store.Buckets(from, to, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := GetGraphParams{
+		SiteID:  siteID,
+		Edges:   store.BucketEdges(buckets, from, to),
+		Filters: filters,
+	}
+	res, err := s.q.GetGraph(s.ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*store.GraphBucket, len(buckets))
+	for _, r := range res {
+		values[r.Bucket] = &store.GraphBucket{
+			Visitors:     int(r.Visitors),
+			Visits:       int(r.Visits),
+			Pageviews:    int(r.Pageviews),
+			Bounces:      int(r.Bounces),
+			Duration:     int(r.Duration),
+			CustomEvents: int(r.CustomEvents),
+			Conversions:  int(r.Conversions),
+			Events:       make(map[string]int),
+		}
+	}
+
+	var names []string
+	for _, m := range metrics {
+		if name, ok := m.Event(); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		events, err := s.q.GetGraphEvents(s.ctx, arg, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			values[e.Bucket].Events[e.EventName] = int(e.Count)
+		}
+	}
+
+	return store.NewGraphStats(interval, metrics, buckets, values), nil
+}
+
+func (s *Store) GetRetention(site string, from time.Time, to time.Time, interval store.Interval, loc *time.Location, filters store.Filters) (*store.Retention, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := store.Buckets(from, to, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetRetention(s.ctx, GetGraphParams{
+		SiteID:  siteID,
+		Edges:   store.BucketEdges(buckets, from, to),
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]*store.RetentionCount, len(res))
+	for i, r := range res {
+		counts[i] = &store.RetentionCount{Cohort: r.Cohort, Period: r.Period, Visitors: int(r.Visitors)}
+	}
+
+	return store.NewRetention(interval, buckets, counts), nil
+}
+
+func (s *Store) GetProps(site string, eventName string) ([]*store.Prop, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetPropsByEventName(s.ctx, GetPropsByEventNameParams{
+		SiteID:    siteID,
+		EventName: eventName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]*store.Prop, len(res))
+	for i, p := range res {
+		props[i] = &store.Prop{
+			EventID:   p.EventID,
+			Key:       p.Key,
+			Value:     p.Value,
+			CreatedAt: p.CreatedAt,
+		}
+	}
+
+	return props, nil
+}
+
+func (s *Store) GetRevenues(site string, eventName string) ([]*store.Revenue, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetRevenuesByEventName(s.ctx, GetRevenuesByEventNameParams{
+		SiteID:    siteID,
+		EventName: eventName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revenues := make([]*store.Revenue, len(res))
+	for i, r := range res {
+		revenues[i] = &store.Revenue{
+			EventID:   r.EventID,
+			Key:       r.Key,
+			Value:     r.Value,
+			CreatedAt: r.CreatedAt,
+		}
+	}
+
+	return revenues, nil
+}
+
+func (s *Store) GetFilteredCounts(site string, from time.Time, to time.Time) ([]*store.FilteredCount, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetFilteredCounts(s.ctx, GetFilteredCountsParams{
+		SiteID: siteID,
+		From:   from.UTC().Format(time.DateOnly),
+		To:     to.UTC().Format(time.DateOnly),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]*store.FilteredCount, len(res))
+	for i, c := range res {
+		counts[i] = &store.FilteredCount{
+			Reason: c.Reason,
+			Count:  int(c.Count),
+		}
+	}
+
+	return counts, nil
+}
+
+func (s *Store) CreateGoal(site string, goal *store.Goal) (*store.Goal, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.q.CreateGoal(s.ctx, CreateGoalParams{
+		SiteID:    siteID,
+		Name:      goal.Name,
+		EventName: goal.EventName,
+		PagePath:  goal.PagePath,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toGoal(g), nil
+}
+
+func (s *Store) GetGoals(site string) ([]*store.Goal, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.ListGoals(s.ctx, siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	goals := make([]*store.Goal, len(res))
+	for i, g := range res {
+		goals[i] = toGoal(g)
+	}
+
+	return goals, nil
+}
+
+func (s *Store) UpdateGoal(site string, goal *store.Goal) (*store.Goal, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.q.UpdateGoal(s.ctx, UpdateGoalParams{
+		Name:      goal.Name,
+		EventName: goal.EventName,
+		PagePath:  goal.PagePath,
+		ID:        goal.ID,
+		SiteID:    siteID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrGoalNotFound
+		}
+		return nil, err
+	}
+
+	return toGoal(g), nil
+}
+
+func (s *Store) DeleteGoal(site string, id int64) error {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.q.DeleteGoal(s.ctx, DeleteGoalParams{ID: id, SiteID: siteID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrGoalNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetGoalStats(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.GoalStats, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	goals, err := s.q.ListGoals(s.ctx, siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetGoalConversions(s.ctx, GetGoalConversionsParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+	conversions := make(map[int64]GetGoalConversionsRow, len(res))
+	for _, r := range res {
+		conversions[r.GoalID] = r
+	}
+
+	// the rate is out of every visitor that matches the filters
+	st, err := s.q.GetStats(s.ctx, GetStatsParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+	visitors := int(st.UniqueVisitors.Int64)
+
+	stats := make([]*store.GoalStats, len(goals))
+	for i, g := range goals {
+		c := conversions[g.ID]
+		stats[i] = &store.GoalStats{
+			Goal:              toGoal(g),
+			UniqueConversions: int(c.UniqueConversions),
+			TotalConversions:  int(c.TotalConversions),
+			ConversionRate:    store.ConversionRate(int(c.UniqueConversions), visitors),
+		}
+	}
+
+	return stats, nil
+}
+
+func toGoal(g Goal) *store.Goal {
+	return &store.Goal{
+		ID:        g.ID,
+		Name:      g.Name,
+		EventName: g.EventName,
+		PagePath:  g.PagePath,
+		CreatedAt: g.CreatedAt,
+	}
+}
+
+func (s *Store) CreateFunnel(site string, funnel *store.Funnel) (*store.Funnel, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := json.Marshal(funnel.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.q.CreateFunnel(s.ctx, CreateFunnelParams{
+		SiteID:        siteID,
+		Name:          funnel.Name,
+		Steps:         string(steps),
+		WindowSeconds: int64(funnel.Window),
+		CreatedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toFunnel(f)
+}
+
+func (s *Store) GetFunnel(site string, id int64) (*store.Funnel, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.q.GetFunnel(s.ctx, GetFunnelParams{ID: id, SiteID: siteID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrFunnelNotFound
+		}
+		return nil, err
+	}
+
+	return toFunnel(f)
+}
+
+func (s *Store) GetFunnels(site string) ([]*store.Funnel, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.ListFunnels(s.ctx, siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	funnels := make([]*store.Funnel, len(res))
+	for i, f := range res {
+		if funnels[i], err = toFunnel(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return funnels, nil
+}
+
+func (s *Store) UpdateFunnel(site string, funnel *store.Funnel) (*store.Funnel, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := json.Marshal(funnel.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.q.UpdateFunnel(s.ctx, UpdateFunnelParams{
+		Name:          funnel.Name,
+		Steps:         string(steps),
+		WindowSeconds: int64(funnel.Window),
+		ID:            funnel.ID,
+		SiteID:        siteID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrFunnelNotFound
+		}
+		return nil, err
+	}
+
+	return toFunnel(f)
+}
+
+func (s *Store) DeleteFunnel(site string, id int64) error {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.q.DeleteFunnel(s.ctx, DeleteFunnelParams{ID: id, SiteID: siteID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrFunnelNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetFunnelStats(site string, id int64, from time.Time, to time.Time, filters store.Filters) (*store.FunnelStats, error) {
+	funnel, err := s.GetFunnel(site, id)
+	if err != nil {
+		return nil, err
+	}
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.q.GetFunnelEvents(s.ctx, GetFunnelEventsParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Steps:   funnel.Steps,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]store.FunnelEvent, len(res))
+	for i, e := range res {
+		events[i] = store.FunnelEvent{SessionID: e.SessionID, At: e.CreatedAt, Steps: e.Steps}
+	}
+
+	return store.NewFunnelStats(funnel, funnel.Count(events)), nil
+}
+
+func toFunnel(f Funnel) (*store.Funnel, error) {
+	funnel := &store.Funnel{
+		ID:        f.ID,
+		Name:      f.Name,
+		Window:    int(f.WindowSeconds),
+		CreatedAt: f.CreatedAt,
+	}
+	if err := json.Unmarshal([]byte(f.Steps), &funnel.Steps); err != nil {
+		return nil, err
+	}
+	return funnel, nil
+}
+
+func (s *Store) GetTotalRevenue(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error) {
+	return s.getRevenue(s.q.GetTotalRevenue, site, from, to, filters)
+}
+
+func (s *Store) GetRevenueByGoal(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error) {
+	return s.getRevenue(s.q.GetRevenueByGoal, site, from, to, filters)
+}
+
+func (s *Store) GetRevenueBySource(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error) {
+	return s.getRevenue(s.q.GetRevenueBySource, site, from, to, filters)
+}
+
+func (s *Store) GetRevenueByCampaign(site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error) {
+	return s.getRevenue(s.q.GetRevenueByCampaign, site, from, to, filters)
+}
+
+func (s *Store) getRevenue(query func(context.Context, GetRevenueParams) ([]GetRevenueRow, error), site string, from time.Time, to time.Time, filters store.Filters) ([]*store.RevenueTotal, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := query(s.ctx, GetRevenueParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make([]*store.RevenueTotal, len(res))
+	for i, r := range res {
+		totals[i] = &store.RevenueTotal{
+			Name:        r.Name,
+			Currency:    r.Currency,
+			Micros:      r.Amount,
+			Conversions: int(r.Conversions),
+		}
+	}
+
+	return totals, nil
+}
+
+func (s *Store) GetRealtime(site string, since time.Time, limit int) (*store.Realtime, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	visitors, err := s.q.GetCurrentVisitors(s.ctx, GetCurrentVisitorsParams{
+		SiteID: siteID,
+		Since:  since.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	arg := GetCurrentParams{SiteID: siteID, Since: since.UTC(), Limit: int64(limit)}
+	pages, err := s.q.GetCurrentPages(s.ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.q.GetCurrentSources(s.ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.Realtime{
+		Visitors: int(visitors),
+		Pages:    toRealtimeCounts(pages),
+		Sources:  toRealtimeCounts(sources),
+	}, nil
+}
+
+func toRealtimeCounts(rows []GetCurrentRow) []*store.RealtimeCount {
+	counts := make([]*store.RealtimeCount, len(rows))
+	for i, r := range rows {
+		counts[i] = &store.RealtimeCount{Name: r.Name, Visitors: int(r.Visitors)}
+	}
+	return counts
+}
+
+func (s *Store) GetCountries(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetCountries, site, from, to, filters, opts)
+}
+
+func (s *Store) GetRegions(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetRegions, site, from, to, filters, opts)
+}
+
+func (s *Store) GetSources(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetSources, site, from, to, filters, opts)
+}
+
+func (s *Store) GetReferrers(site string, source string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(func(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+		return s.q.GetReferrers(ctx, arg, source)
+	}, site, from, to, filters, opts)
+}
+
+func (s *Store) GetUTMSources(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetUTMSources, site, from, to, filters, opts)
+}
+
+func (s *Store) GetUTMMediums(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetUTMMediums, site, from, to, filters, opts)
+}
+
+func (s *Store) GetUTMCampaigns(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetUTMCampaigns, site, from, to, filters, opts)
+}
+
+func (s *Store) GetUTMTerms(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetUTMTerms, site, from, to, filters, opts)
+}
+
+func (s *Store) GetUTMContents(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetUTMContents, site, from, to, filters, opts)
+}
+
+func (s *Store) GetBrowsers(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetBrowsers, site, from, to, filters, opts)
+}
+
+func (s *Store) GetBrowserVersions(site string, browser string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(func(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+		return s.q.GetBrowserVersions(ctx, arg, browser)
+	}, site, from, to, filters, opts)
+}
+
+func (s *Store) GetOperatingSystems(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetOperatingSystems, site, from, to, filters, opts)
+}
+
+func (s *Store) GetOSVersions(site string, os string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(func(ctx context.Context, arg GetBreakdownParams) ([]GetBreakdownRow, error) {
+		return s.q.GetOSVersions(ctx, arg, os)
+	}, site, from, to, filters, opts)
+}
+
+func (s *Store) GetScreenTypes(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetScreenTypes, site, from, to, filters, opts)
+}
+
+func (s *Store) GetLanguages(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	return s.getBreakdown(s.q.GetLanguages, site, from, to, filters, opts)
+}
+
+func (s *Store) getBreakdown(query func(context.Context, GetBreakdownParams) ([]GetBreakdownRow, error), site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.Breakdown, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = store.DefaultLimit
+	}
+
+	res, err := query(s.ctx, GetBreakdownParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Sort:    opts.Sort,
+		Limit:   int64(limit),
+		Offset:  int64(opts.Offset),
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*store.Breakdown, len(res))
+	for i, r := range res {
+		rows[i] = &store.Breakdown{
+			Name:           r.Name,
+			Country:        r.Country,
+			Visitors:       int(r.Visitors),
+			Percentage:     int(r.Percentage),
+			Visits:         int(r.Visits),
+			Pageviews:      int(r.Pageviews),
+			BounceRate:     int(r.BounceRate),
+			VisitDuration:  int(r.VisitDuration),
+			CustomEvents:   int(r.CustomEvents),
+			Conversions:    int(r.Conversions),
+			ConversionRate: store.ConversionRate(int(r.Conversions), int(r.Visitors)),
+		}
+	}
+
+	return rows, nil
+}
+
+func (s *Store) GetPages(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.PageStats, error) {
+	return s.getPageStats(s.q.GetPages, site, from, to, filters, opts)
+}
+
+func (s *Store) GetEntryPages(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.PageStats, error) {
+	return s.getPageStats(s.q.GetEntryPages, site, from, to, filters, opts)
+}
+
+func (s *Store) GetExitPages(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.PageStats, error) {
+	return s.getPageStats(s.q.GetExitPages, site, from, to, filters, opts)
+}
+
+func (s *Store) getPageStats(query func(context.Context, GetPageStatsParams) ([]GetPageStatsRow, error), site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.PageStats, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = store.DefaultLimit
+	}
+
+	res, err := query(s.ctx, GetPageStatsParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Sort:    opts.Sort,
+		Limit:   int64(limit),
+		Offset:  int64(opts.Offset),
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]*store.PageStats, len(res))
+	for i, r := range res {
+		pages[i] = &store.PageStats{
+			Url:         r.Url,
+			Visitors:    int(r.Visitors),
+			Pageviews:   int(r.Pageviews),
+			BounceRate:  int(r.BounceRate),
+			TimeOnPage:  int(r.TimeOnPage),
+			ScrollDepth: int(r.ScrollDepth),
+		}
+	}
+
+	return pages, nil
+}
+
+func (s *Store) GetOutboundLinks(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+	return s.getLinks(s.q.GetOutboundLinks, site, from, to, filters, opts)
+}
+
+func (s *Store) GetFileDownloads(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+	return s.getLinks(s.q.GetFileDownloads, site, from, to, filters, opts)
+}
+
+func (s *Store) GetBrokenLinks(site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+	return s.getLinks(s.q.GetBrokenLinks, site, from, to, filters, opts)
+}
+
+func (s *Store) GetBrokenLinkReferrers(site string, url string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+	return s.getLinks(func(ctx context.Context, arg GetLinksParams) ([]GetLinksRow, error) {
+		return s.q.GetBrokenLinkReferrers(ctx, arg, url)
+	}, site, from, to, filters, opts)
+}
+
+func (s *Store) getLinks(query func(context.Context, GetLinksParams) ([]GetLinksRow, error), site string, from time.Time, to time.Time, filters store.Filters, opts store.ListOptions) ([]*store.LinkStats, error) {
+	siteID, err := s.siteID(site)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = store.DefaultLimit
+	}
+
+	res, err := query(s.ctx, GetLinksParams{
+		SiteID:  siteID,
+		From:    from,
+		To:      to,
+		Sort:    opts.Sort,
+		Limit:   int64(limit),
+		Offset:  int64(opts.Offset),
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*store.LinkStats, len(res))
+	for i, r := range res {
+		rows[i] = &store.LinkStats{
+			Name:       r.Name,
+			Visitors:   int(r.Visitors),
+			Percentage: int(r.Percentage),
+			Events:     int(r.Events),
+		}
+	}
+
+	return rows, nil
+}
+
+func (s *Store) CreateSalt(day time.Time, value []byte) (*store.Salt, error) {
+	if err := s.q.CreateSalt(s.ctx, CreateSaltParams{
+		Day:       day.UTC().Format(time.DateOnly),
+		Value:     value,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+
+	// another instance may have created the salt first
+	return s.GetSalt(day)
+}
+
+func (s *Store) GetSalt(day time.Time) (*store.Salt, error) {
+	salt, err := s.q.GetSalt(s.ctx, day.UTC().Format(time.DateOnly))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrSaltNotFound
+		}
+		return nil, err
+	}
+
+	return &store.Salt{
+		Day:       salt.Day,
+		Value:     salt.Value,
+		CreatedAt: salt.CreatedAt,
+	}, nil
+}
+
+func (s *Store) DeleteSalts(day time.Time) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// overwrite the salts before deleting them so they do not linger in free pages
+	q := s.withTx(tx)
+	d := day.UTC().Format(time.DateOnly)
+	if err := q.ClearSalts(s.ctx, d); err != nil {
+		return err
+	}
+	if err := q.DeleteSalts(s.ctx, d); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
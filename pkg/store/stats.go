@@ -1,15 +1,36 @@
 package store
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
 type Stats struct {
-	PageViews            int `json:"page_views"`
-	Visitors             int `json:"visitors"`
-	Bounces              int `json:"bounces"`
+	PageViews int `json:"page_views"`
+	Visitors  int `json:"visitors"`
+	Bounces   int `json:"bounces"`
+	// AverageSessionLength is in seconds, for visits with engagement events
+	// it is their engaged time.
 	AverageSessionLength int `json:"average_session_length"`
+	// TimeOnPage and ScrollDepth average the engagement events of the pages
+	// viewed, see PageStats.
+	TimeOnPage  int `json:"time_on_page"`
+	ScrollDepth int `json:"scroll_depth"`
 }
 
 type Diff struct {
-	Value  int `json:"value"`
-	Change int `json:"change"`
+	Value int `json:"value"`
+	// Previous is the value in the period compared with.
+	Previous int `json:"previous"`
+	Change   int `json:"change"`
+	// Percent is Change as a percentage of Previous. It is null when there is
+	// nothing to compare with, or when Previous is zero and Value is not, as
+	// growth from nothing has no percentage.
+	Percent *float64 `json:"percent"`
 }
 
 type StatsDiff struct {
@@ -17,24 +38,56 @@ type StatsDiff struct {
 	Visitors             *Diff `json:"visitors"`
 	Bounces              *Diff `json:"bounces"`
 	AverageSessionLength *Diff `json:"average_session_length"`
+	TimeOnPage           *Diff `json:"time_on_page"`
+	ScrollDepth          *Diff `json:"scroll_depth"`
+	// Goals are the conversions of each goal of the site.
+	Goals []*GoalDiff `json:"goals"`
 }
 
+// Calculate compares the stats with prev, a nil prev means there is nothing
+// to compare with.
 func (s *Stats) Calculate(prev *Stats) *StatsDiff {
 	if prev == nil {
 		return &StatsDiff{
-			PageViews:            &Diff{Value: s.PageViews, Change: 0},
-			Visitors:             &Diff{Value: s.Visitors, Change: 0},
-			Bounces:              &Diff{Value: s.Bounces, Change: 0},
-			AverageSessionLength: &Diff{Value: s.AverageSessionLength, Change: 0},
+			PageViews:            &Diff{Value: s.PageViews},
+			Visitors:             &Diff{Value: s.Visitors},
+			Bounces:              &Diff{Value: s.Bounces},
+			AverageSessionLength: &Diff{Value: s.AverageSessionLength},
+			TimeOnPage:           &Diff{Value: s.TimeOnPage},
+			ScrollDepth:          &Diff{Value: s.ScrollDepth},
 		}
 	}
 
 	return &StatsDiff{
-		PageViews:            &Diff{Value: s.PageViews, Change: s.PageViews - prev.PageViews},
-		Visitors:             &Diff{Value: s.Visitors, Change: s.Visitors - prev.Visitors},
-		Bounces:              &Diff{Value: s.Bounces, Change: s.Bounces - prev.Bounces},
-		AverageSessionLength: &Diff{Value: s.AverageSessionLength, Change: s.AverageSessionLength - prev.AverageSessionLength},
+		PageViews:            newDiff(s.PageViews, prev.PageViews),
+		Visitors:             newDiff(s.Visitors, prev.Visitors),
+		Bounces:              newDiff(s.Bounces, prev.Bounces),
+		AverageSessionLength: newDiff(s.AverageSessionLength, prev.AverageSessionLength),
+		TimeOnPage:           newDiff(s.TimeOnPage, prev.TimeOnPage),
+		ScrollDepth:          newDiff(s.ScrollDepth, prev.ScrollDepth),
+	}
+}
+
+func newDiff(value, prev int) *Diff {
+	return &Diff{
+		Value:    value,
+		Previous: prev,
+		Change:   value - prev,
+		Percent:  percentChange(float64(value), float64(prev)),
+	}
+}
+
+// percentChange returns the change from prev to value as a percentage of prev
+// rounded to one decimal place, or nil when prev is zero and value is not.
+func percentChange(value, prev float64) *float64 {
+	var p float64
+	switch {
+	case prev != 0:
+		p = math.Round((value-prev)*1000/prev) / 10
+	case value != 0:
+		return nil
 	}
+	return &p
 }
 
 type Coord struct {
@@ -43,7 +96,221 @@ type Coord struct {
 }
 
 type GraphStats struct {
-	Period    string   `json:"period"`
-	PageViews []*Coord `json:"page_views"`
-	Visitors  []*Coord `json:"visitors"`
+	Period string `json:"period"`
+	// Interval is the length of each bucket, X is the start of the bucket as
+	// an RFC 3339 time in the time zone of the request.
+	Interval Interval `json:"interval"`
+	Metrics  []Metric `json:"metrics"`
+	// Series holds every bucket of the range for each metric, oldest first,
+	// with zero for the buckets without visits.
+	Series map[Metric][]*Coord `json:"series"`
+	// Comparison is the same graph over the period compared with, when one
+	// was asked for. Its buckets line up with these by index.
+	Comparison *GraphStats `json:"comparison,omitempty"`
+}
+
+// Metric is a value a graph plots for each bucket, it is one of the Metric
+// constants or EventMetricPrefix followed by the name of an event.
+type Metric string
+
+const (
+	MetricVisitors  Metric = "visitors"
+	MetricVisits    Metric = "visits"
+	MetricPageviews Metric = "pageviews"
+	// MetricBounceRate is the percentage of visits with one pageview.
+	MetricBounceRate Metric = "bounce_rate"
+	// MetricVisitDuration is the average length of a visit in seconds.
+	MetricVisitDuration Metric = "visit_duration"
+	// MetricCustomEvents is the number of visits with a custom event.
+	MetricCustomEvents Metric = "custom_events"
+	// MetricConversions is the number of visitors that reached any goal, the
+	// conversions of a single goal are graphed with its event metric.
+	MetricConversions Metric = "conversions"
+)
+
+// EventMetricPrefix starts a metric that counts an event, e.g. "event:Signup".
+const EventMetricPrefix = "event:"
+
+// DefaultMetrics are graphed when no metric is asked for.
+var DefaultMetrics = []Metric{MetricVisitors, MetricPageviews}
+
+// ParseMetrics parses metrics separated by ",", leaving out repeated ones. An
+// empty string is DefaultMetrics.
+func ParseMetrics(s string) ([]Metric, error) {
+	var metrics []Metric
+	for _, v := range strings.Split(s, ",") {
+		m := Metric(strings.TrimSpace(v))
+		switch {
+		case m == "":
+			continue
+		case slices.Contains(metrics, m):
+			continue
+		}
+
+		switch m {
+		case MetricVisitors, MetricVisits, MetricPageviews, MetricBounceRate, MetricVisitDuration, MetricCustomEvents, MetricConversions:
+		default:
+			if name, ok := m.Event(); !ok || name == "" {
+				return nil, fmt.Errorf("unknown metric %q", m)
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	if len(metrics) == 0 {
+		return DefaultMetrics, nil
+	}
+	return metrics, nil
+}
+
+// Event returns the name of the event that an event metric counts.
+func (m Metric) Event() (string, bool) {
+	return strings.CutPrefix(string(m), EventMetricPrefix)
+}
+
+// GraphBucket holds the sums the metrics of a bucket are worked out from.
+type GraphBucket struct {
+	Visitors  int
+	Visits    int
+	Pageviews int
+	Bounces   int
+	// Duration is the total length of the visits in seconds.
+	Duration int
+	// CustomEvents is the number of visits with a custom event.
+	CustomEvents int
+	// Conversions is the number of visitors that reached any goal.
+	Conversions int
+	// Events counts the events of each event metric by name.
+	Events map[string]int
+}
+
+func (b *GraphBucket) Value(m Metric) int {
+	switch m {
+	case MetricVisitors:
+		return b.Visitors
+	case MetricVisits:
+		return b.Visits
+	case MetricPageviews:
+		return b.Pageviews
+	case MetricBounceRate:
+		if b.Visits == 0 {
+			return 0
+		}
+		return b.Bounces * 100 / b.Visits
+	case MetricVisitDuration:
+		if b.Visits == 0 {
+			return 0
+		}
+		return b.Duration / b.Visits
+	case MetricCustomEvents:
+		return b.CustomEvents
+	case MetricConversions:
+		return b.Conversions
+	}
+	name, _ := m.Event()
+	return b.Events[name]
+}
+
+// NewGraphStats returns the series of each metric, starts are the starts of
+// the buckets and values the sums of each bucket in the same order.
+func NewGraphStats(interval Interval, metrics []Metric, starts []time.Time, values []*GraphBucket) *GraphStats {
+	graph := &GraphStats{
+		Interval: interval,
+		Metrics:  metrics,
+		Series:   make(map[Metric][]*Coord, len(metrics)),
+	}
+	for _, m := range metrics {
+		series := make([]*Coord, len(starts))
+		for i, start := range starts {
+			series[i] = &Coord{X: start.Format(time.RFC3339), Y: values[i].Value(m)}
+		}
+		graph.Series[m] = series
+	}
+	return graph
+}
+
+// Interval is the length of the buckets of a graph.
+type Interval string
+
+const (
+	IntervalMinute Interval = "minute"
+	IntervalHour   Interval = "hour"
+	IntervalDay    Interval = "day"
+	// IntervalWeek starts on Monday, as ISO 8601 weeks do.
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// ParseInterval checks that s is one of the intervals.
+func ParseInterval(s string) (Interval, error) {
+	switch i := Interval(s); i {
+	case IntervalMinute, IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+		return i, nil
+	}
+	return "", fmt.Errorf("invalid interval %q, must be minute, hour, day, week or month", s)
+}
+
+// MaxBuckets is the most buckets a graph may have.
+const MaxBuckets = 1000
+
+var ErrTooManyBuckets = errors.New("too many buckets, use a longer interval")
+
+// Truncate returns the start of the interval t is in. Days, weeks and months
+// start at midnight in loc, minutes and hours at their start in loc.
+func (i Interval) Truncate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch i {
+	case IntervalMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case IntervalWeek:
+		monday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-monday, 0, 0, 0, 0, loc)
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// Next returns the start of the interval after the one starting at t, days
+// are calendar days so they may be 23 or 25 hours long.
+func (i Interval) Next(t time.Time) time.Time {
+	switch i {
+	case IntervalMinute:
+		return t.Add(time.Minute)
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// Buckets returns the start of every interval from the one from is in up to
+// the one to is in, aligned to loc.
+func Buckets(from, to time.Time, interval Interval, loc *time.Location) ([]time.Time, error) {
+	var buckets []time.Time
+	for t := interval.Truncate(from, loc); !t.After(to); t = interval.Next(t) {
+		if len(buckets) == MaxBuckets {
+			return nil, ErrTooManyBuckets
+		}
+		buckets = append(buckets, t)
+	}
+	return buckets, nil
+}
+
+// BucketEdges returns the bounds of buckets over the range from to to, the
+// first bucket starts at from rather than the start of its interval, and the
+// last ends just after to as the range includes it.
+func BucketEdges(buckets []time.Time, from, to time.Time) []time.Time {
+	edges := make([]time.Time, len(buckets)+1)
+	copy(edges, buckets)
+	edges[0] = from
+	edges[len(buckets)] = to.Add(time.Nanosecond)
+	return edges
 }
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		value, prev float64
+		// want is nil when there is no percentage
+		want *float64
+	}{
+		{110, 100, ptr(10)},
+		{90, 100, ptr(-10)},
+		{0, 5, ptr(-100)},
+		{2, 3, ptr(-33.3)},
+		{1, 3, ptr(-66.7)},
+		{1000, 3, ptr(33233.3)},
+		{0, 0, ptr(0)},
+		{5, 0, nil},
+	}
+	for _, tt := range tests {
+		got := percentChange(tt.value, tt.prev)
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("percentChange(%g, %g) = %g, want nil", tt.value, tt.prev, *got)
+		case tt.want != nil && (got == nil || *got != *tt.want):
+			t.Errorf("percentChange(%g, %g) = %v, want %g", tt.value, tt.prev, got, *tt.want)
+		}
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func TestBuckets(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		interval Interval
+		loc      *time.Location
+		// want is the start of each bucket in loc
+		want string
+	}{
+		{"minutes", "2024-03-15T10:00:30Z", "2024-03-15T10:02:00Z", IntervalMinute, time.UTC,
+			"2024-03-15T10:00:00Z 2024-03-15T10:01:00Z 2024-03-15T10:02:00Z"},
+		{"hours", "2024-03-15T10:30:00Z", "2024-03-15T13:00:00Z", IntervalHour, time.UTC,
+			"2024-03-15T10:00:00Z 2024-03-15T11:00:00Z 2024-03-15T12:00:00Z 2024-03-15T13:00:00Z"},
+		// 01:00 is skipped as the clocks go forward
+		{"hours on a short day", "2024-03-31T00:00:00Z", "2024-03-31T03:00:00Z", IntervalHour, london,
+			"2024-03-31T00:00:00Z 2024-03-31T02:00:00+01:00 2024-03-31T03:00:00+01:00 2024-03-31T04:00:00+01:00"},
+		{"short day", "2024-03-30T12:00:00Z", "2024-04-01T12:00:00Z", IntervalDay, london,
+			"2024-03-30T00:00:00Z 2024-03-31T00:00:00Z 2024-04-01T00:00:00+01:00"},
+		{"long day", "2024-10-26T12:00:00Z", "2024-10-28T12:00:00Z", IntervalDay, london,
+			"2024-10-26T00:00:00+01:00 2024-10-27T00:00:00+01:00 2024-10-28T00:00:00Z"},
+		// it is already the next day in Auckland
+		{"days ahead of UTC", "2024-03-15T11:30:00Z", "2024-03-16T11:30:00Z", IntervalDay, auckland,
+			"2024-03-16T00:00:00+13:00 2024-03-17T00:00:00+13:00"},
+		{"weeks", "2024-03-13T12:00:00Z", "2024-03-27T12:00:00Z", IntervalWeek, time.UTC,
+			"2024-03-11T00:00:00Z 2024-03-18T00:00:00Z 2024-03-25T00:00:00Z"},
+		{"months", "2024-01-15T00:00:00Z", "2024-03-15T00:00:00Z", IntervalMonth, auckland,
+			"2024-01-01T00:00:00+13:00 2024-02-01T00:00:00+13:00 2024-03-01T00:00:00+13:00"},
+		{"to before from", "2024-03-15T10:00:00Z", "2024-03-15T09:00:00Z", IntervalHour, time.UTC, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets, err := Buckets(mustParse(t, tt.from), mustParse(t, tt.to), tt.interval, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, b := range buckets {
+				got = append(got, b.In(tt.loc).Format(time.RFC3339))
+			}
+			if got := strings.Join(got, " "); got != tt.want {
+				t.Errorf("Buckets = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketsMax(t *testing.T) {
+	from := mustParse(t, "2024-03-15T00:00:00Z")
+
+	buckets, err := Buckets(from, from.Add((MaxBuckets-1)*time.Minute), IntervalMinute, time.UTC)
+	if err != nil || len(buckets) != MaxBuckets {
+		t.Errorf("Buckets of %d minutes = %d buckets, %v", MaxBuckets, len(buckets), err)
+	}
+
+	if _, err := Buckets(from, from.Add(MaxBuckets*time.Minute), IntervalMinute, time.UTC); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("Buckets of %d minutes error = %v, want %v", MaxBuckets+1, err, ErrTooManyBuckets)
+	}
+}
+
+func TestBucketEdges(t *testing.T) {
+	from, to := mustParse(t, "2024-03-15T10:30:00Z"), mustParse(t, "2024-03-15T12:59:59.999999999Z")
+	buckets, err := Buckets(from, to, IntervalHour, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range BucketEdges(buckets, from, to) {
+		got = append(got, e.Format(time.RFC3339Nano))
+	}
+	want := "2024-03-15T10:30:00Z 2024-03-15T11:00:00Z 2024-03-15T12:00:00Z 2024-03-15T13:00:00Z"
+	if got := strings.Join(got, " "); got != want {
+		t.Errorf("BucketEdges = %s, want %s", got, want)
+	}
+}
+
+func mustParse(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseMetrics(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Metric
+	}{
+		{"", DefaultMetrics},
+		{" , ", DefaultMetrics},
+		{"visitors", []Metric{MetricVisitors}},
+		{"conversions,custom_events", []Metric{MetricConversions, MetricCustomEvents}},
+		{"event:Signup, visits,event:Signup", []Metric{"event:Signup", MetricVisits}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetrics(tt.in)
+		if err != nil {
+			t.Errorf("ParseMetrics(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseMetrics(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"goals", "event:", "visitors,Conversions"} {
+		if _, err := ParseMetrics(in); err == nil {
+			t.Errorf("ParseMetrics(%q) did not fail", in)
+		}
+	}
+}
@@ -0,0 +1,1414 @@
+// Package storetest is a conformance suite for store.DBClient
+// implementations. Every backend is expected to pass it, e.g.
+//
+//	func TestSqlite(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.DBClient {
+//			db, err := sqlite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return db
+//		})
+//	}
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+	"github.com/danecwalker/gotrack/pkg/store"
+)
+
+// Open returns a client backed by an empty database.
+type Open func(t *testing.T) store.DBClient
+
+// Run runs every conformance test against the clients returned by open.
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db store.DBClient)
+	}{
+		{"Sites", testSites},
+		{"InsertEvent", testInsertEvent},
+		{"InsertRecords", testInsertRecords},
+		{"Stats", testStats},
+		{"Graph", testGraph},
+		{"TimeZones", testTimeZones},
+		{"SiteScope", testSiteScope},
+		{"Visits", testVisits},
+		{"Locations", testLocations},
+		{"Sources", testSources},
+		{"UTM", testUTM},
+		{"Devices", testDevices},
+		{"Filters", testFilters},
+		{"Pages", testPages},
+		{"Paths", testPaths},
+		{"Engagement", testEngagement},
+		{"Links", testLinks},
+		{"Filtered", testFiltered},
+		{"Goals", testGoals},
+		{"Funnels", testFunnels},
+		{"Revenue", testRevenue},
+		{"Realtime", testRealtime},
+		{"Retention", testRetention},
+		{"Salts", testSalts},
+		{"PreviousSession", testPreviousSession},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := open(t)
+			t.Cleanup(func() {
+				db.Close()
+			})
+			tt.fn(t, db)
+		})
+	}
+}
+
+func mustCreateSite(t *testing.T, db store.DBClient, domain string) *store.Site {
+	t.Helper()
+	site, err := db.CreateSite(domain)
+	if err != nil {
+		t.Fatalf("CreateSite(%q): %v", domain, err)
+	}
+	return site
+}
+
+func record(domain string, sessionID string, name string, url string, at time.Time) *store.Record {
+	s := &event.Session{
+		SessionID:  sessionID,
+		Domain:     domain,
+		Browser:    "Firefox",
+		Os:         "Linux",
+		ScreenType: event.Desktop,
+		CreatedAt:  at,
+	}
+	ev := event.NewWEvent(domain, sessionID)
+	ev.EventName = name
+	ev.Url = url
+	ev.CreatedAt = at
+	return &store.Record{Session: s, Event: ev}
+}
+
+func testSites(t *testing.T, db store.DBClient) {
+	site := mustCreateSite(t, db, "www.Example.com")
+	if site.Domain != "example.com" {
+		t.Errorf("domain = %q, want %q", site.Domain, "example.com")
+	}
+
+	for _, domain := range []string{"example.com", "WWW.example.com"} {
+		if _, err := db.CreateSite(domain); !errors.Is(err, store.ErrSiteExists) {
+			t.Errorf("CreateSite(%q) error = %v, want %v", domain, err, store.ErrSiteExists)
+		}
+	}
+
+	got, err := db.GetSite("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != site.ID {
+		t.Errorf("GetSite id = %d, want %d", got.ID, site.ID)
+	}
+
+	if _, err := db.GetSite("unknown.com"); !errors.Is(err, store.ErrSiteNotFound) {
+		t.Errorf("GetSite(unknown) error = %v, want %v", err, store.ErrSiteNotFound)
+	}
+
+	mustCreateSite(t, db, "another.com")
+	sites, err := db.GetSites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 2 || sites[0].Domain != "another.com" {
+		t.Errorf("GetSites = %+v, want 2 sites ordered by domain", sites)
+	}
+}
+
+func testInsertEvent(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	r := record("example.com", "s1", "New Sale", "https://example.com/buy", time.Now().UTC())
+	r.Event.Props = map[string]interface{}{"plan": "pro", "seats": 3.0}
+	r.Event.Revenue = map[string]interface{}{"amount": "20", "currency": "USD"}
+
+	if err := db.InsertSession(r.Session); err != nil {
+		t.Fatal(err)
+	}
+	// inserting the same session twice is not an error
+	if err := db.InsertSession(r.Session); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.InsertEvent(r.Event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == 0 {
+		t.Error("InsertEvent returned a zero id")
+	}
+
+	props, err := db.GetProps("example.com", "New Sale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 2 {
+		t.Fatalf("got %d props, want 2", len(props))
+	}
+	values := map[string]string{}
+	for _, p := range props {
+		if p.EventID != id {
+			t.Errorf("prop event id = %d, want %d", p.EventID, id)
+		}
+		values[p.Key] = p.Value
+	}
+	if values["plan"] != "pro" || values["seats"] != "3" {
+		t.Errorf("props = %v", values)
+	}
+
+	revenues, err := db.GetRevenues("example.com", "New Sale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revenues) != 2 {
+		t.Fatalf("got %d revenues, want 2", len(revenues))
+	}
+
+	if _, err := db.InsertEvent(record("unknown.com", "s2", "pageview", "https://unknown.com/", time.Now()).Event); !errors.Is(err, store.ErrSiteNotFound) {
+		t.Errorf("InsertEvent(unknown site) error = %v, want %v", err, store.ErrSiteNotFound)
+	}
+}
+
+func testInsertRecords(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	err := db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		record("unknown.com", "s2", "pageview", "https://unknown.com/", now),
+		record("example.com", "s1", "pageview", "https://example.com/about", now.Add(time.Second)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetStats("example.com", now.Add(-time.Minute), now.Add(time.Minute), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PageViews != 2 {
+		t.Errorf("page views = %d, want 2", stats.PageViews)
+	}
+}
+
+func testStats(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	err := db.InsertRecords([]*store.Record{
+		// a bounce
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		// a two page visit lasting a minute
+		record("example.com", "s2", "pageview", "https://example.com/", now),
+		record("example.com", "s2", "pageview", "https://example.com/pricing", now.Add(time.Minute)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetStats("example.com", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := store.Stats{PageViews: 3, Visitors: 2, Bounces: 1, AverageSessionLength: 30}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	empty, err := db.GetStats("example.com", now.Add(-48*time.Hour), now.Add(-24*time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *empty != (store.Stats{}) {
+		t.Errorf("stats for an empty period = %+v, want zero", *empty)
+	}
+}
+
+func testGraph(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	// 23:00 and 01:00 either side of midnight in UTC+10, both on March 1 in UTC
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	late := time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)
+	early := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	if _, err := db.CreateGoal("example.com", &store.Goal{EventName: "signup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", late),
+		record("example.com", "s2", "pageview", "https://example.com/", early),
+		record("example.com", "s2", "signup", "https://example.com/", early.Add(30*time.Second)),
+		record("example.com", "s2", "pageview", "https://example.com/pricing", early.Add(time.Minute)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the third day has no visits and is still in the graph
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 3).Add(-time.Nanosecond)
+	metrics := []store.Metric{store.MetricVisitors, store.MetricPageviews, store.MetricBounceRate, store.MetricVisitDuration, store.MetricCustomEvents, store.MetricConversions, "event:signup"}
+	graph, err := db.GetGraph("example.com", from, to, store.IntervalDay, loc, metrics, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// oldest first
+	x := []string{"2024-03-01T00:00:00+10:00", "2024-03-02T00:00:00+10:00", "2024-03-03T00:00:00+10:00"}
+	want := map[store.Metric][]int{
+		store.MetricVisitors:      {1, 1, 0},
+		store.MetricPageviews:     {1, 2, 0},
+		store.MetricBounceRate:    {100, 0, 0},
+		store.MetricVisitDuration: {0, 60, 0},
+		store.MetricCustomEvents:  {0, 1, 0},
+		store.MetricConversions:   {0, 1, 0},
+		"event:signup":            {0, 1, 0},
+	}
+	for m, values := range want {
+		series := graph.Series[m]
+		if len(series) != len(values) {
+			t.Errorf("got %d %s buckets, want %d", len(series), m, len(values))
+			continue
+		}
+		for i, v := range values {
+			if series[i].X != x[i] || series[i].Y != v {
+				t.Errorf("%s[%d] = %+v, want {X:%s Y:%d}", m, i, *series[i], x[i], v)
+			}
+		}
+	}
+}
+
+func testTimeZones(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	brisbane := time.FixedZone("AEST", 10*60*60)
+
+	err := db.InsertRecords([]*store.Record{
+		// 23:00 on October 17 in Brisbane
+		record("example.com", "s1", "pageview", "https://example.com/a", time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)),
+		// 06:00 on October 18 in Brisbane
+		record("example.com", "s2", "pageview", "https://example.com/b", time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)),
+		// 06:00 on October 19 in Brisbane
+		record("example.com", "s3", "pageview", "https://example.com/c", time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// October 18 in Brisbane, with the bounds in Brisbane time
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, brisbane)
+	to := from.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	stats, err := db.GetStats("example.com", from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PageViews != 1 || stats.Visitors != 1 {
+		t.Errorf("stats = %+v, want 1 page view by 1 visitor", *stats)
+	}
+
+	pages, err := db.GetPages("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if pages[0].Url != "https://example.com/b" {
+		t.Errorf("page = %s, want https://example.com/b", pages[0].Url)
+	}
+
+	browsers, err := db.GetBrowsers("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(browsers) != 1 || browsers[0].Visitors != 1 {
+		t.Errorf("got %d browsers, want 1 with 1 visitor", len(browsers))
+	}
+}
+
+func testSiteScope(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "a.com")
+	mustCreateSite(t, db, "b.com")
+	now := time.Now().UTC()
+
+	err := db.InsertRecords([]*store.Record{
+		record("a.com", "s1", "pageview", "https://a.com/", now),
+		record("b.com", "s2", "pageview", "https://b.com/", now),
+		record("b.com", "s2", "pageview", "https://b.com/x", now.Add(time.Second)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for site, want := range map[string]int{"a.com": 1, "b.com": 2} {
+		stats, err := db.GetStats(site, now.Add(-time.Minute), now.Add(time.Minute), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.PageViews != want {
+			t.Errorf("%s page views = %d, want %d", site, stats.PageViews, want)
+		}
+	}
+
+	if _, err := db.GetStats("c.com", now.Add(-time.Minute), now, nil); !errors.Is(err, store.ErrSiteNotFound) {
+		t.Errorf("GetStats(unknown site) error = %v, want %v", err, store.ErrSiteNotFound)
+	}
+}
+
+func testVisits(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-6 * time.Hour)
+	timeout := time.Duration(event.SessionTimeout) * time.Second
+
+	err := db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		record("example.com", "s1", "signup", "https://example.com/", now.Add(time.Minute)),
+		// the same session coming back after the timeout is a new visit
+		record("example.com", "s1", "pageview", "https://example.com/blog", now.Add(time.Minute+timeout+time.Second)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetStats("example.com", now.Add(-time.Minute), now.Add(6*time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := store.Stats{PageViews: 2, Visitors: 1, Bounces: 1, AverageSessionLength: 30}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+}
+
+func testLocations(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	located := func(sessionID string, country string, region string) *store.Record {
+		r := record("example.com", sessionID, "pageview", "https://example.com/", now)
+		r.Session.Country = country
+		r.Session.Region = region
+		r.Session.City = "Somewhere"
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		located("s1", "NZ", "Auckland"),
+		located("s2", "NZ", "Wellington"),
+		located("s3", "NZ", "Auckland"),
+		located("s4", "GB", "England"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countries, err := db.GetCountries("example.com", now.Add(-time.Minute), now.Add(time.Minute), nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 2 || countries[0].Name != "NZ" || countries[0].Visitors != 3 || countries[1].Name != "GB" {
+		t.Errorf("countries = %+v", countries)
+	}
+
+	regions, err := db.GetRegions("example.com", now.Add(-time.Minute), now.Add(time.Minute), nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := store.Breakdown{Name: "Auckland", Country: "NZ", Visitors: 2, Percentage: 50, Visits: 2, Pageviews: 2, BounceRate: 100}
+	if len(regions) != 3 || *regions[0] != want {
+		t.Errorf("regions = %+v, want %+v first", regions, want)
+	}
+}
+
+func testSources(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	referred := func(sessionID string, referrer string) *store.Record {
+		r := record("example.com", sessionID, "pageview", "https://example.com/", now)
+		r.Event.Referrer, r.Event.ReferrerSource = event.NormalizeReferrer(referrer, "example.com")
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		referred("s1", "https://www.google.com/search"),
+		referred("s2", "https://google.co.uk/"),
+		referred("s3", "https://t.co/abc"),
+		referred("s4", ""),
+		// a self-referral counts as no referrer
+		referred("s5", "https://www.example.com/blog"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Minute)
+	sources, err := db.GetSources("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range sources {
+		names = append(names, fmt.Sprintf("%s:%d", s.Name, s.Visitors))
+	}
+	if got, want := strings.Join(names, ","), ":2,Google:2,Twitter:1"; got != want {
+		t.Errorf("sources = %s, want %s", got, want)
+	}
+
+	referrers, err := db.GetReferrers("example.com", "Google", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referrers) != 2 || referrers[0].Name != "https://google.co.uk/" || referrers[1].Name != "https://www.google.com/search" {
+		t.Errorf("referrers = %+v", referrers)
+	}
+}
+
+func testUTM(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	campaign := func(r *store.Record, source string, campaign string) *store.Record {
+		r.Event.UTM = &event.UTM{Source: source, Medium: "email", Campaign: campaign}
+		return r
+	}
+	// an event goal reached by s1 and a page goal reached by s3
+	for _, g := range []*store.Goal{{EventName: "signup"}, {PagePath: "/pricing"}} {
+		if _, err := db.CreateGoal("example.com", g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := db.InsertRecords([]*store.Record{
+		// the visit is attributed to the first event, even though the second
+		// one came from another campaign
+		campaign(record("example.com", "s1", "pageview", "https://example.com/", now), "newsletter", "launch"),
+		campaign(record("example.com", "s1", "signup", "https://example.com/", now.Add(2*time.Second)), "twitter", "other"),
+		campaign(record("example.com", "s2", "pageview", "https://example.com/", now), "newsletter", "launch"),
+		campaign(record("example.com", "s3", "pageview", "https://example.com/pricing", now), "twitter", ""),
+		record("example.com", "s4", "pageview", "https://example.com/", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Minute)
+	sources, err := db.GetUTMSources("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.Breakdown{
+		{Name: "newsletter", Visitors: 2, Percentage: 66, Visits: 2, Pageviews: 2, BounceRate: 50, VisitDuration: 1, CustomEvents: 1, Conversions: 1, ConversionRate: 50},
+		{Name: "twitter", Visitors: 1, Percentage: 33, Visits: 1, Pageviews: 1, BounceRate: 100, Conversions: 1, ConversionRate: 100},
+	}
+	if len(sources) != len(want) {
+		t.Fatalf("utm sources = %+v, want %+v", sources, want)
+	}
+	for i := range want {
+		if *sources[i] != want[i] {
+			t.Errorf("utm source %d = %+v, want %+v", i, *sources[i], want[i])
+		}
+	}
+
+	campaigns, err := db.GetUTMCampaigns("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 1 || campaigns[0].Name != "launch" || campaigns[0].Visits != 2 {
+		t.Errorf("utm campaigns = %+v", campaigns)
+	}
+}
+
+func testDevices(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	device := func(r *store.Record, browser string, version string, screen event.ScreenType) *store.Record {
+		r.Session.Browser = browser
+		r.Session.BrowserVersion = version
+		r.Session.Os = "Windows"
+		r.Session.OsVersion = "10.0"
+		r.Session.ScreenType = screen
+		r.Session.Language = "en"
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		device(record("example.com", "s1", "pageview", "https://example.com/", now), "Chrome", "120", event.Desktop),
+		// a visitor with two visits is one visitor
+		device(record("example.com", "s1", "pageview", "https://example.com/", now.Add(2*time.Hour)), "Chrome", "120", event.Desktop),
+		device(record("example.com", "s2", "pageview", "https://example.com/", now), "Chrome", "119", event.Mobile),
+		device(record("example.com", "s3", "pageview", "https://example.com/", now), "Firefox", "121", event.Mobile),
+		device(record("example.com", "s4", "pageview", "https://example.com/", now), "Safari", "17", event.Mobile),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(3*time.Hour)
+	check := func(name string, got []*store.Breakdown, err error, want string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []string
+		for _, r := range got {
+			rows = append(rows, fmt.Sprintf("%s:%d:%d%%", r.Name, r.Visitors, r.Percentage))
+		}
+		if got := strings.Join(rows, ","); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+
+	browsers, err := db.GetBrowsers("example.com", from, to, nil, store.ListOptions{})
+	check("browsers", browsers, err, "Chrome:2:50%,Firefox:1:25%,Safari:1:25%")
+
+	versions, err := db.GetBrowserVersions("example.com", "Chrome", from, to, nil, store.ListOptions{})
+	check("chrome versions", versions, err, "119:1:50%,120:1:50%")
+
+	systems, err := db.GetOperatingSystems("example.com", from, to, nil, store.ListOptions{})
+	check("operating systems", systems, err, "Windows:4:100%")
+
+	osVersions, err := db.GetOSVersions("example.com", "Windows", from, to, nil, store.ListOptions{})
+	check("windows versions", osVersions, err, "10.0:4:100%")
+
+	screens, err := db.GetScreenTypes("example.com", from, to, nil, store.ListOptions{})
+	check("screen types", screens, err, "mobile:3:75%,desktop:1:25%")
+
+	languages, err := db.GetLanguages("example.com", from, to, nil, store.ListOptions{})
+	check("languages", languages, err, "en:4:100%")
+}
+
+func testFilters(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	visitor := func(r *store.Record, country string, browser string) *store.Record {
+		r.Session.Country = country
+		r.Session.Browser = browser
+		return r
+	}
+	s1 := visitor(record("example.com", "s1", "pageview", "https://example.com/blog/a", now), "AU", "Chrome")
+	s1.Event.UTM = &event.UTM{Source: "newsletter"}
+	signup := visitor(record("example.com", "s3", "signup", "https://example.com/blog/b", now.Add(time.Second)), "US", "Chrome")
+	signup.Event.Props = map[string]interface{}{"plan": "pro"}
+	err := db.InsertRecords([]*store.Record{
+		s1,
+		visitor(record("example.com", "s1", "pageview", "https://example.com/pricing", now.Add(time.Second)), "AU", "Chrome"),
+		visitor(record("example.com", "s2", "pageview", "https://example.com/", now), "NZ", "Firefox"),
+		visitor(record("example.com", "s3", "pageview", "https://example.com/blog/b", now), "US", "Chrome"),
+		signup,
+		visitor(record("example.com", "s4", "pageview", "https://example.com/", now), "AU", "Safari"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Minute)
+	for _, tt := range []struct {
+		filters  string
+		visitors int
+	}{
+		{"", 4},
+		{"page==/blog/*", 2},
+		{"page!=/blog/*", 2},
+		{"page==/pricing|/", 3},
+		{"entry_page==/blog/a", 1},
+		{"country==AU|NZ", 3},
+		{"utm_source!=newsletter", 3},
+		{"browser~^Chr", 2},
+		{"browser!~Chrome", 2},
+		{"event==signup", 1},
+		{"props.plan==pro", 1},
+		{"props.plan!=pro", 3},
+		{"page==/blog/*;country!=AU", 1},
+	} {
+		filters, err := store.ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := db.GetStats("example.com", from, to, filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.filters, err)
+		}
+		if stats.Visitors != tt.visitors {
+			t.Errorf("%s: visitors = %d, want %d", tt.filters, stats.Visitors, tt.visitors)
+		}
+	}
+
+	filters, err := store.ParseFilters("browser==Chrome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	countries, err := db.GetCountries("example.com", from, to, filters, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 2 || countries[0].Name != "AU" || countries[1].Name != "US" || countries[0].Percentage != 50 {
+		t.Errorf("countries with %v = %+v", filters, countries)
+	}
+
+	filters, err = store.ParseFilters("country==AU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := db.GetPages("example.com", from, to, filters, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Errorf("pages with %v = %+v", filters, pages)
+	}
+}
+
+func testPages(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	err := db.InsertRecords([]*store.Record{
+		// a bounce on the home page
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		// home, pricing for a minute, then signup
+		record("example.com", "s2", "pageview", "https://example.com/", now),
+		record("example.com", "s2", "pageview", "https://example.com/pricing", now.Add(20*time.Second)),
+		record("example.com", "s2", "pageview", "https://example.com/signup", now.Add(80*time.Second)),
+		// straight to pricing, then leave after looking at it twice
+		record("example.com", "s3", "pageview", "https://example.com/pricing", now),
+		record("example.com", "s3", "pageview", "https://example.com/pricing", now.Add(40*time.Second)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Hour)
+	check := func(name string, got []*store.PageStats, err error, want []store.PageStats) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d rows, want %d: %+v", name, len(got), len(want), got)
+		}
+		for i := range want {
+			if *got[i] != want[i] {
+				t.Errorf("%s row %d = %+v, want %+v", name, i, *got[i], want[i])
+			}
+		}
+	}
+
+	pages, err := db.GetPages("example.com", from, to, nil, store.ListOptions{Sort: "pageviews"})
+	check("pages", pages, err, []store.PageStats{
+		{Url: "https://example.com/pricing", Visitors: 2, Pageviews: 3, BounceRate: 0, TimeOnPage: 50},
+		{Url: "https://example.com/", Visitors: 2, Pageviews: 2, BounceRate: 50, TimeOnPage: 20},
+		{Url: "https://example.com/signup", Visitors: 1, Pageviews: 1, BounceRate: 0, TimeOnPage: 0},
+	})
+
+	paged, err := db.GetPages("example.com", from, to, nil, store.ListOptions{Sort: "pageviews", Limit: 1, Offset: 1})
+	check("second page", paged, err, []store.PageStats{
+		{Url: "https://example.com/", Visitors: 2, Pageviews: 2, BounceRate: 50, TimeOnPage: 20},
+	})
+
+	entry, err := db.GetEntryPages("example.com", from, to, nil, store.ListOptions{})
+	check("entry pages", entry, err, []store.PageStats{
+		{Url: "https://example.com/", Visitors: 2, Pageviews: 2, BounceRate: 50, TimeOnPage: 20},
+		{Url: "https://example.com/pricing", Visitors: 1, Pageviews: 1, BounceRate: 0, TimeOnPage: 40},
+	})
+
+	exit, err := db.GetExitPages("example.com", from, to, nil, store.ListOptions{})
+	check("exit pages", exit, err, []store.PageStats{
+		{Url: "https://example.com/", Visitors: 1, Pageviews: 1, BounceRate: 100, TimeOnPage: 0},
+		{Url: "https://example.com/pricing", Visitors: 1, Pageviews: 1, BounceRate: 0, TimeOnPage: 0},
+		{Url: "https://example.com/signup", Visitors: 1, Pageviews: 1, BounceRate: 0, TimeOnPage: 0},
+	})
+}
+
+func testPaths(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+	home, err := db.CreateGoal("example.com", &store.Goal{PagePath: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com", now),
+		record("example.com", "s2", "pageview", "https://example.com/?ref=a", now),
+		record("example.com", "s3", "pageview", "https://example.com?ref=b", now),
+		record("example.com", "s4", "pageview", "https://example.com/blog#top", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every backend reads the path of a url the same way, a url without a
+	// path is on "/"
+	from, to := now.Add(-time.Minute), now.Add(time.Minute)
+	for _, tt := range []struct {
+		filters  string
+		visitors int
+	}{
+		{"page==/", 1},
+		{"entry_page==/", 1},
+		{"page==/*", 4},
+		{"page==/?ref=*", 2},
+		{"page==/blog*", 1},
+		{"page~^/$", 1},
+		{"exit_page~^/\\?", 2},
+	} {
+		filters, err := store.ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := db.GetStats("example.com", from, to, filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.filters, err)
+		}
+		if stats.Visitors != tt.visitors {
+			t.Errorf("%s: visitors = %d, want %d", tt.filters, stats.Visitors, tt.visitors)
+		}
+	}
+
+	goals, err := db.GetGoalStats("example.com", from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 1 || goals[0].Goal.ID != home.ID || goals[0].UniqueConversions != 1 {
+		t.Errorf("got %d goals, want %s reached by 1 visitor", len(goals), home.Name)
+	}
+}
+
+func testEngagement(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	engagement := func(sessionID string, url string, at time.Time, d time.Duration, scrollDepth int) *store.Record {
+		r := record("example.com", sessionID, event.EngagementEvent, url, at)
+		r.Event.Engagement = &event.Engagement{Time: d, ScrollDepth: scrollDepth}
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		engagement("s1", "https://example.com/", now.Add(5*time.Second), 10*time.Second, 80),
+		record("example.com", "s1", "pageview", "https://example.com/blog", now.Add(time.Minute)),
+		// the page was hidden and shown again, so it is reported twice
+		engagement("s1", "https://example.com/blog", now.Add(90*time.Second), 30*time.Second, 50),
+		engagement("s1", "https://example.com/blog", now.Add(2*time.Minute), 20*time.Second, 100),
+		// a single pageview read for ten minutes
+		record("example.com", "s2", "pageview", "https://example.com/", now),
+		engagement("s2", "https://example.com/", now.Add(10*time.Minute), 10*time.Minute, 40),
+		// engagement never starts a visit
+		engagement("s3", "https://example.com/", now, time.Minute, 100),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Hour)
+	stats, err := db.GetStats("example.com", from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the engagement is not an interaction, so s2 is still a bounce
+	want := store.Stats{PageViews: 3, Visitors: 2, Bounces: 1, AverageSessionLength: 330, TimeOnPage: 220, ScrollDepth: 73}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	pages, err := db.GetPages("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPages := []store.PageStats{
+		{Url: "https://example.com/", Visitors: 2, Pageviews: 2, BounceRate: 50, TimeOnPage: 305, ScrollDepth: 60},
+		{Url: "https://example.com/blog", Visitors: 1, Pageviews: 1, BounceRate: 0, TimeOnPage: 50, ScrollDepth: 100},
+	}
+	if len(pages) != len(wantPages) {
+		t.Fatalf("got %d pages, want %d: %+v", len(pages), len(wantPages), pages)
+	}
+	for i, p := range pages {
+		if *p != wantPages[i] {
+			t.Errorf("page %d = %+v, want %+v", i, *p, wantPages[i])
+		}
+	}
+}
+
+func testLinks(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	link := func(sessionID string, name string, url string, referrer string, at time.Time) *store.Record {
+		r := record("example.com", sessionID, name, "https://example.com/", at)
+		r.Event.Props["url"] = url
+		if referrer != "" {
+			r.Event.Props["referrer"] = referrer
+		}
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		link("s1", event.OutboundLinkEvent, "https://github.com/example", "", now.Add(time.Second)),
+		link("s1", event.FileDownloadEvent, "https://example.com/report.pdf", "", now.Add(2*time.Second)),
+		link("s1", event.OutboundLinkEvent, "https://github.com/example", "", now.Add(3*time.Second)),
+		record("example.com", "s2", "pageview", "https://example.com/", now),
+		link("s2", event.OutboundLinkEvent, "https://github.com/example", "", now.Add(time.Second)),
+		link("s2", event.OutboundLinkEvent, "https://twitter.com/example", "", now.Add(2*time.Second)),
+		record("example.com", "s3", "pageview", "https://example.com/missing", now),
+		link("s3", event.NotFoundEvent, "https://example.com/missing", "https://example.com/blog", now.Add(time.Second)),
+		record("example.com", "s4", "pageview", "https://example.com/missing", now),
+		link("s4", event.NotFoundEvent, "https://example.com/missing", "", now.Add(time.Second)),
+		record("example.com", "s5", "pageview", "https://example.com/old", now),
+		link("s5", event.NotFoundEvent, "https://example.com/old", "https://news.example.org/", now.Add(time.Second)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Hour)
+	check := func(name string, got []*store.LinkStats, err error, want string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []string
+		for _, r := range got {
+			rows = append(rows, fmt.Sprintf("%s:%d:%d%%:%d", r.Name, r.Visitors, r.Percentage, r.Events))
+		}
+		if got := strings.Join(rows, ","); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+
+	outbound, err := db.GetOutboundLinks("example.com", from, to, nil, store.ListOptions{Sort: "events"})
+	check("outbound links", outbound, err, "https://github.com/example:2:100%:3,https://twitter.com/example:1:50%:1")
+
+	downloads, err := db.GetFileDownloads("example.com", from, to, nil, store.ListOptions{})
+	check("file downloads", downloads, err, "https://example.com/report.pdf:1:100%:1")
+
+	broken, err := db.GetBrokenLinks("example.com", from, to, nil, store.ListOptions{})
+	check("broken links", broken, err, "https://example.com/missing:2:66%:2,https://example.com/old:1:33%:1")
+
+	// a broken link on the site itself keeps its referrer
+	referrers, err := db.GetBrokenLinkReferrers("example.com", "https://example.com/missing", from, to, nil, store.ListOptions{})
+	check("broken link referrers", referrers, err, ":1:50%:1,https://example.com/blog:1:50%:1")
+
+	// link events come with the pageview they are about, so they neither end a
+	// bounce nor count as custom events
+	sources, err := db.GetSources("example.com", from, to, nil, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("sources = %d rows, want 1", len(sources))
+	}
+	if got := *sources[0]; got.Visits != 5 || got.BounceRate != 100 || got.CustomEvents != 0 {
+		t.Errorf("sources = %+v, want 5 bounced visits without custom events", got)
+	}
+}
+
+func testFiltered(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	dropped := record("example.com", "s1", "pageview", "https://example.com/", now)
+	dropped.Event.BotReason = event.BotCrawler
+	dropped.Discard = true
+	flagged := record("example.com", "s2", "pageview", "https://example.com/", now)
+	flagged.Event.BotReason = event.BotHeadless
+	another := record("example.com", "s3", "pageview", "https://example.com/", now)
+	another.Event.BotReason = event.BotCrawler
+	another.Discard = true
+
+	err := db.InsertRecords([]*store.Record{
+		dropped,
+		flagged,
+		another,
+		record("example.com", "s4", "pageview", "https://example.com/", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flagged events are stored but left out of the stats
+	stats, err := db.GetStats("example.com", now.Add(-time.Minute), now.Add(time.Minute), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PageViews != 1 {
+		t.Errorf("page views = %d, want 1", stats.PageViews)
+	}
+
+	counts, err := db.GetFilteredCounts("example.com", now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.FilteredCount{
+		{Reason: event.BotCrawler, Count: 2},
+		{Reason: event.BotHeadless, Count: 1},
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("got %d filtered counts, want %d", len(counts), len(want))
+	}
+	for i, c := range counts {
+		if *c != want[i] {
+			t.Errorf("filtered count %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+}
+
+func testGoals(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	mustCreateSite(t, db, "other.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	signup, err := db.CreateGoal("example.com", &store.Goal{Name: "Signup", EventName: "Form Submit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pricing, err := db.CreateGoal("example.com", &store.Goal{Name: "Pricing", PagePath: "/pricing*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateGoal("other.com", &store.Goal{Name: "Other", EventName: "Form Submit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.InsertRecords([]*store.Record{
+		record("example.com", "s1", "pageview", "https://example.com/", now),
+		record("example.com", "s1", "Form Submit", "https://example.com/", now.Add(time.Second)),
+		record("example.com", "s2", "pageview", "https://example.com/pricing", now),
+		record("example.com", "s2", "pageview", "https://example.com/pricing/teams", now.Add(time.Second)),
+		record("example.com", "s3", "pageview", "https://example.com/", now),
+		record("other.com", "s4", "Form Submit", "https://other.com/", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goals, err := db.GetGoals("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 2 || goals[0].ID != signup.ID || goals[1].ID != pricing.ID {
+		t.Fatalf("goals = %+v, want signup and pricing", goals)
+	}
+
+	for _, tt := range []struct {
+		filters string
+		want    map[string][3]float64
+	}{
+		// unique conversions, total conversions and conversion rate
+		{"", map[string][3]float64{"Signup": {1, 1, 33.3}, "Pricing": {1, 2, 33.3}}},
+		{"page==/pricing", map[string][3]float64{"Signup": {0, 0, 0}, "Pricing": {1, 2, 100}}},
+	} {
+		filters, err := store.ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := db.GetGoalStats("example.com", now.Add(-time.Minute), now.Add(time.Minute), filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != len(tt.want) {
+			t.Fatalf("%q: got %d goals, want %d", tt.filters, len(stats), len(tt.want))
+		}
+		for _, st := range stats {
+			got := [3]float64{float64(st.UniqueConversions), float64(st.TotalConversions), st.ConversionRate}
+			if got != tt.want[st.Goal.Name] {
+				t.Errorf("%q: %s = %v, want %v", tt.filters, st.Goal.Name, got, tt.want[st.Goal.Name])
+			}
+		}
+	}
+
+	signup.Name = "Sign up"
+	updated, err := db.UpdateGoal("example.com", signup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Sign up" || updated.EventName != "Form Submit" {
+		t.Errorf("updated goal = %+v", updated)
+	}
+
+	if _, err := db.UpdateGoal("other.com", signup); !errors.Is(err, store.ErrGoalNotFound) {
+		t.Errorf("UpdateGoal(other site) error = %v, want %v", err, store.ErrGoalNotFound)
+	}
+	if err := db.DeleteGoal("other.com", pricing.ID); !errors.Is(err, store.ErrGoalNotFound) {
+		t.Errorf("DeleteGoal(other site) error = %v, want %v", err, store.ErrGoalNotFound)
+	}
+	if err := db.DeleteGoal("example.com", pricing.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	goals, err = db.GetGoals("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 1 {
+		t.Errorf("got %d goals after delete, want 1", len(goals))
+	}
+}
+
+func testFunnels(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	mustCreateSite(t, db, "other.com")
+	now := time.Now().UTC().Add(-2 * time.Hour)
+
+	funnel, err := db.CreateFunnel("example.com", &store.Funnel{
+		Name: "Signup",
+		Steps: []store.FunnelStep{
+			{Name: "Landing", PagePath: "/"},
+			{Name: "Pricing", PagePath: "/pricing"},
+			{Name: "Signup", EventName: "Signup"},
+		},
+		Window: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(m int) time.Time { return now.Add(time.Duration(m) * time.Minute) }
+	err = db.InsertRecords([]*store.Record{
+		// every step in order
+		record("example.com", "s1", "pageview", "https://example.com/", at(0)),
+		record("example.com", "s1", "pageview", "https://example.com/pricing", at(1)),
+		record("example.com", "s1", "Signup", "https://example.com/pricing", at(2)),
+		// left at pricing
+		record("example.com", "s2", "pageview", "https://example.com/", at(0)),
+		record("example.com", "s2", "pageview", "https://example.com/pricing", at(1)),
+		// pricing before landing does not count
+		record("example.com", "s3", "pageview", "https://example.com/pricing", at(0)),
+		record("example.com", "s3", "pageview", "https://example.com/", at(1)),
+		// signed up after the window
+		record("example.com", "s4", "pageview", "https://example.com/", at(0)),
+		record("example.com", "s4", "pageview", "https://example.com/pricing", at(1)),
+		record("example.com", "s4", "Signup", "https://example.com/pricing", at(20)),
+		// the second landing starts a run that fits in the window
+		record("example.com", "s5", "pageview", "https://example.com/", at(0)),
+		record("example.com", "s5", "pageview", "https://example.com/", at(15)),
+		record("example.com", "s5", "pageview", "https://example.com/pricing", at(16)),
+		record("example.com", "s5", "Signup", "https://example.com/pricing", at(17)),
+		// never landed
+		record("example.com", "s6", "Signup", "https://example.com/signup", at(0)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetFunnelStats("example.com", funnel.ID, now.Add(-time.Minute), now.Add(time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.FunnelStepStats{
+		{Visitors: 5, ConversionRate: 100},
+		{Visitors: 4, ConversionRate: 80, DropOff: 1, DropOffRate: 20},
+		{Visitors: 2, ConversionRate: 40, DropOff: 2, DropOffRate: 50},
+	}
+	if len(stats.Steps) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(stats.Steps), len(want))
+	}
+	for i, w := range want {
+		w.Step = funnel.Steps[i]
+		if *stats.Steps[i] != w {
+			t.Errorf("step %d = %+v, want %+v", i+1, *stats.Steps[i], w)
+		}
+	}
+
+	if _, err := db.GetFunnelStats("other.com", funnel.ID, now, now, nil); !errors.Is(err, store.ErrFunnelNotFound) {
+		t.Errorf("GetFunnelStats(other site) error = %v, want %v", err, store.ErrFunnelNotFound)
+	}
+
+	funnel.Window = 0
+	if _, err := db.UpdateFunnel("example.com", funnel); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = db.GetFunnelStats("example.com", funnel.ID, now.Add(-time.Minute), now.Add(time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stats.Steps[2].Visitors; got != 3 {
+		t.Errorf("signups without a window = %d, want 3", got)
+	}
+
+	if err := db.DeleteFunnel("example.com", funnel.ID); err != nil {
+		t.Fatal(err)
+	}
+	funnels, err := db.GetFunnels("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funnels) != 0 {
+		t.Errorf("got %d funnels after delete, want 0", len(funnels))
+	}
+}
+
+func testRevenue(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC().Add(-time.Hour)
+
+	if _, err := db.CreateGoal("example.com", &store.Goal{Name: "Purchase", EventName: "purchase"}); err != nil {
+		t.Fatal(err)
+	}
+
+	purchase := func(sessionID string, campaign string, micros int64, currency string) *store.Record {
+		r := record("example.com", sessionID, "purchase", "https://example.com/checkout", now)
+		r.Event.Money = &event.Money{Micros: micros, Currency: currency}
+		if campaign != "" {
+			r.Event.UTM = &event.UTM{Source: "newsletter", Campaign: campaign}
+		}
+		return r
+	}
+	err := db.InsertRecords([]*store.Record{
+		purchase("s1", "launch", 10_500_000, "USD"),
+		purchase("s2", "launch", 20_000_000, "EUR"),
+		purchase("s3", "", 4_250_000, "USD"),
+		purchase("s4", "", 1_000_000_000, "JPY"),
+		record("example.com", "s5", "signup", "https://example.com/", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := now.Add(-time.Minute), now.Add(time.Minute)
+	total, err := db.GetTotalRevenue("example.com", from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.RevenueTotal{
+		{Currency: "EUR", Micros: 20_000_000, Conversions: 1},
+		{Currency: "JPY", Micros: 1_000_000_000, Conversions: 1},
+		{Currency: "USD", Micros: 14_750_000, Conversions: 2},
+	}
+	if len(total) != len(want) {
+		t.Fatalf("got %d totals, want %d", len(total), len(want))
+	}
+	for i, r := range total {
+		if *r != want[i] {
+			t.Errorf("total %d = %+v, want %+v", i, *r, want[i])
+		}
+	}
+
+	rates := &store.ExchangeRates{Currency: "USD", Rates: map[string]float64{"EUR": 1.1}}
+	got := rates.Total(total)
+	if got.Total != 36.75 || got.Conversions != 3 || got.Average != 12.25 || len(got.Unconverted) != 1 || got.Unconverted[0] != "JPY" {
+		t.Errorf("converted total = %+v", got)
+	}
+
+	goals, err := db.GetRevenueByGoal("example.com", from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 3 || goals[0].Name != "Purchase" {
+		t.Errorf("revenue by goal = %+v, want every currency for Purchase", goals)
+	}
+
+	filters, err := store.ParseFilters("utm_campaign==launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	campaigns, err := db.GetRevenueByCampaign("example.com", from, to, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := rates.Convert(campaigns)
+	if len(stats) != 1 || stats[0].Name != "launch" || stats[0].Total != 32.5 || stats[0].Conversions != 2 {
+		t.Errorf("revenue by campaign = %+v", stats)
+	}
+}
+
+func testRealtime(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	err := db.InsertRecords([]*store.Record{
+		// s1 left before the window
+		record("example.com", "s1", "pageview", "https://example.com/old", now.Add(-10*time.Minute)),
+		record("example.com", "s2", "pageview", "https://example.com/", now.Add(-time.Minute)),
+		record("example.com", "s2", "pageview", "https://example.com/blog", now),
+		record("example.com", "s3", "pageview", "https://example.com/blog", now),
+		record("example.com", "s4", "signup", "https://example.com/", now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := db.GetRealtime("example.com", now.Add(-store.RealtimeWindow), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Visitors != 3 {
+		t.Errorf("visitors = %d, want 3", live.Visitors)
+	}
+	pages := []store.RealtimeCount{{Name: "/blog", Visitors: 2}, {Name: "/", Visitors: 1}}
+	if len(live.Pages) != len(pages) {
+		t.Fatalf("got %d pages, want %d", len(live.Pages), len(pages))
+	}
+	for i, p := range live.Pages {
+		if *p != pages[i] {
+			t.Errorf("page %d = %+v, want %+v", i, *p, pages[i])
+		}
+	}
+	if len(live.Sources) != 1 || live.Sources[0].Visitors != 3 {
+		t.Errorf("sources = %+v, want one without a name", live.Sources)
+	}
+}
+
+func testRetention(t *testing.T, db store.DBClient) {
+	site := mustCreateSite(t, db, "example.com")
+	if site.Privacy != store.PrivacyStrict {
+		t.Errorf("privacy = %q, want %q", site.Privacy, store.PrivacyStrict)
+	}
+	if _, err := db.SetSitePrivacy("unknown.com", store.PrivacyReturning); !errors.Is(err, store.ErrSiteNotFound) {
+		t.Errorf("SetSitePrivacy(unknown) error = %v, want %v", err, store.ErrSiteNotFound)
+	}
+
+	// a Monday, so each week is one cohort
+	week := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	visit := func(sessionID string, visitorID string, path string, at time.Time) *store.Record {
+		r := record("example.com", sessionID, "pageview", "https://example.com"+path, at)
+		r.Session.VisitorID = visitorID
+		return r
+	}
+
+	// ids are not kept while the site is strict
+	if err := db.InsertRecords([]*store.Record{visit("s0", "x", "/", week)}); err != nil {
+		t.Fatal(err)
+	}
+	site, err := db.SetSitePrivacy("example.com", store.PrivacyReturning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.Privacy != store.PrivacyReturning {
+		t.Errorf("privacy = %q, want %q", site.Privacy, store.PrivacyReturning)
+	}
+
+	err = db.InsertRecords([]*store.Record{
+		visit("s1", "a", "/", week),
+		visit("s2", "b", "/", week.Add(time.Hour)),
+		visit("s3", "a", "/", week.AddDate(0, 0, 7)),
+		visit("s4", "c", "/blog", week.AddDate(0, 0, 8)),
+		visit("s5", "b", "/blog", week.AddDate(0, 0, 14)),
+		// without an id the visitor cannot be followed
+		visit("s6", "", "/", week.AddDate(0, 0, 14)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := week.Add(-12*time.Hour), week.AddDate(0, 0, 20)
+	for _, tt := range []struct {
+		filters string
+		want    [][]int
+	}{
+		{"", [][]int{{2, 1, 1}, {1, 0}, {0}}},
+		// filters pick the cohort by its first visit
+		{"page==/blog", [][]int{{0, 0, 0}, {1, 0}, {0}}},
+	} {
+		filters, err := store.ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret, err := db.GetRetention("example.com", from, to, store.IntervalWeek, time.UTC, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ret.Cohorts) != len(tt.want) {
+			t.Fatalf("%q: got %d cohorts, want %d", tt.filters, len(ret.Cohorts), len(tt.want))
+		}
+		for i, c := range ret.Cohorts {
+			got := make([]int, len(c.Periods))
+			for p, period := range c.Periods {
+				got[p] = period.Visitors
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want[i]) {
+				t.Errorf("%q: cohort %s = %v, want %v", tt.filters, c.Start, got, tt.want[i])
+			}
+		}
+	}
+
+	ret, err := db.GetRetention("example.com", from, to, store.IntervalWeek, time.UTC, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := ret.Cohorts[0]; c.Visitors != 2 || c.Periods[1].Percent != 50 {
+		t.Errorf("first cohort = %d visitors, %v%% in week 2, want 2 and 50%%", c.Visitors, c.Periods[1].Percent)
+	}
+}
+
+func testSalts(t *testing.T, db store.DBClient) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.Add(-24 * time.Hour)
+
+	if _, err := db.GetSalt(today); !errors.Is(err, store.ErrSaltNotFound) {
+		t.Errorf("GetSalt(missing) error = %v, want %v", err, store.ErrSaltNotFound)
+	}
+
+	first, err := db.CreateSalt(today, []byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the salt that was stored first wins
+	second, err := db.CreateSalt(today, []byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first.Value) != "first" || string(second.Value) != "first" {
+		t.Errorf("salt values = %q, %q, want %q", first.Value, second.Value, "first")
+	}
+	if !second.Day.Equal(today) {
+		t.Errorf("salt day = %v, want %v", second.Day, today)
+	}
+
+	if _, err := db.CreateSalt(yesterday, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteSalts(today); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetSalt(yesterday); !errors.Is(err, store.ErrSaltNotFound) {
+		t.Errorf("GetSalt(deleted) error = %v, want %v", err, store.ErrSaltNotFound)
+	}
+	if _, err := db.GetSalt(today); err != nil {
+		t.Errorf("GetSalt(today) error = %v", err)
+	}
+}
+
+func testPreviousSession(t *testing.T, db store.DBClient) {
+	mustCreateSite(t, db, "example.com")
+	now := time.Now().UTC()
+
+	before := record("example.com", "old", "pageview", "https://example.com/", now)
+	after := record("example.com", "new", "pageview", "https://example.com/about", now.Add(time.Minute))
+	after.Session.PreviousID = "old"
+	fresh := record("example.com", "s2", "pageview", "https://example.com/", now)
+	fresh.Session.PreviousID = "unknown"
+
+	if err := db.InsertRecords([]*store.Record{before}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRecords([]*store.Record{after, fresh}); err != nil {
+		t.Fatal(err)
+	}
+
+	if after.Event.SessionID != "old" {
+		t.Errorf("session id = %q, want the previous id %q", after.Event.SessionID, "old")
+	}
+	if fresh.Event.SessionID != "s2" {
+		t.Errorf("session id = %q, want %q", fresh.Event.SessionID, "s2")
+	}
+
+	stats, err := db.GetStats("example.com", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Visitors != 2 || stats.Bounces != 1 {
+		t.Errorf("stats = %+v, want 2 visitors and 1 bounce", *stats)
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"time"
+
+	"github.com/danecwalker/gotrack/pkg/event"
+)
+
+// Visit is a run of events from one session where no two events are more
+// than event.SessionTimeout apart. Visits are kept up to date on ingest so
+// reports never have to rebuild them from the events.
+type Visit struct {
+	ID        int64
+	SiteID    int64
+	SessionID string
+	StartedAt time.Time
+	EndedAt   time.Time
+	EntryUrl  string
+	ExitUrl   string
+	Pageviews int
+	// Events counts the events that show the visitor doing something, link
+	// events are left out as they come with a click or page that is counted.
+	Events int
+	// EngagementTime is the engaged time the engagement events of the visit
+	// add up to, it is zero for tags that do not send them.
+	EngagementTime time.Duration
+	// Referrer, ReferrerSource and UTM are taken from the first event of the
+	// visit.
+	Referrer       string
+	ReferrerSource string
+	UTM            *event.UTM
+}
+
+func NewVisit(siteID int64, ev *event.WEvent) *Visit {
+	v := &Visit{
+		SiteID:         siteID,
+		SessionID:      ev.SessionID,
+		StartedAt:      ev.CreatedAt,
+		EndedAt:        ev.CreatedAt,
+		EntryUrl:       ev.Url,
+		ExitUrl:        ev.Url,
+		Referrer:       ev.Referrer,
+		ReferrerSource: ev.ReferrerSource,
+		UTM:            ev.UTM,
+	}
+	v.count(ev)
+	return v
+}
+
+// Continues reports whether ev is part of the visit rather than the start of
+// a new one. Engagement is always part of the last visit, as it is reported
+// for a page that was viewed in it.
+func (v *Visit) Continues(ev *event.WEvent) bool {
+	if ev.IsEngagement() {
+		return true
+	}
+	timeout := time.Duration(event.SessionTimeout) * time.Second
+	return ev.CreatedAt.Sub(v.EndedAt) <= timeout
+}
+
+// Add counts ev towards the visit, events that arrive out of order may move
+// the entry or exit page. Engagement only adds its time and keeps the visit
+// going.
+func (v *Visit) Add(ev *event.WEvent) {
+	if ev.IsEngagement() {
+		if ev.Engagement != nil {
+			v.EngagementTime += ev.Engagement.Time
+		}
+		if ev.CreatedAt.After(v.EndedAt) {
+			v.EndedAt = ev.CreatedAt
+		}
+		return
+	}
+
+	if ev.CreatedAt.Before(v.StartedAt) {
+		v.StartedAt = ev.CreatedAt
+		v.EntryUrl = ev.Url
+	}
+	if !ev.CreatedAt.Before(v.EndedAt) {
+		v.EndedAt = ev.CreatedAt
+		v.ExitUrl = ev.Url
+	}
+
+	v.count(ev)
+}
+
+// count adds ev to the events and pageviews of the visit.
+func (v *Visit) count(ev *event.WEvent) {
+	if event.IsLinkEvent(ev.EventName) {
+		return
+	}
+	v.Events++
+	if ev.EventName == "pageview" {
+		v.Pageviews++
+	}
+}
+
+// IsBounce reports whether the visit ended after a single event, not counting
+// link events.
+func (v *Visit) IsBounce() bool {
+	return v.Events <= 1
+}
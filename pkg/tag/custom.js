@@ -1,5 +1,10 @@
 {{define "custom"}}
 var PARENT_LIMIT = 3;
+// clicks on links to files of these types are sent as downloads, the
+// data-file-types attribute replaces them with its own comma separated list
+var FILE_TYPES = (currentScript.getAttribute('data-file-types') || 'pdf,xlsx,docx,txt,rtf,csv,exe,key,pps,ppt,pptx,7z,pkg,rar,gz,zip,avi,mov,mp4,mpeg,wmv,midi,mp3,wav,wma,dmg,iso,msi')
+  .split(',')
+  .map(function(type) { return type.trim().toLowerCase().replace(/^\./, '') });
 
 function isForm(element) {
   return element && element.tagName && element.tagName.toLowerCase() === 'form'
@@ -9,6 +14,13 @@ function isOutboundLink(link) {
   return link && link.href && link.host && link.host !== location.host
 }
 
+function isDownloadLink(link) {
+  if (!link || !link.href || !link.pathname) { return false }
+  var file = link.pathname.split('/').pop();
+  var dot = file.lastIndexOf('.');
+  return dot !== -1 && FILE_TYPES.indexOf(file.substr(dot + 1).toLowerCase()) !== -1
+}
+
 function isLink(element) {
   return element && element.tagName && element.tagName.toLowerCase() === 'a'
 }
@@ -99,7 +111,9 @@ function handleTaggedClickEvent(event) {
       g(eventAttr.name, attr)
     }
   } else {
-    if (clickedLink && isOutboundLink(clickedLink)) {
+    if (clickedLink && isDownloadLink(clickedLink)) {
+      sendLinkClickEvent(event, clickedLink, { name: 'filedownload', props: { url: clickedLink.href } })
+    } else if (clickedLink && isOutboundLink(clickedLink)) {
       sendLinkClickEvent(event, clickedLink, { name: 'outboundlink', props: { url: clickedLink.href } })
     }
   }
@@ -6,8 +6,29 @@ import (
 	"strings"
 )
 
-func ApplyCors(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+// AllowedOrigins are the origins allowed to call the api, "*" allows any.
+var AllowedOrigins = []string{"*"}
+
+func ApplyCors(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	allowed := ""
+	for _, o := range AllowedOrigins {
+		if o == "*" {
+			allowed = "*"
+			break
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			allowed = origin
+		}
+	}
+	if allowed == "" {
+		return
+	}
+	if allowed != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 	w.Header().Set("Access-Control-Max-Age", "86400")
@@ -54,7 +75,7 @@ func AllTag(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(err.Error()))
 		return
 	}
-	ApplyCors(w)
+	ApplyCors(w, r)
 	w.Header().Set("Content-Type", "application/javascript")
 	accept := r.Header.Get("Accept-Encoding")
 	if strings.Contains(accept, "gzip") {
@@ -93,7 +114,7 @@ func MinTag(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(err.Error()))
 		return
 	}
-	ApplyCors(w)
+	ApplyCors(w, r)
 	w.Header().Set("Content-Type", "application/javascript")
 	accept := r.Header.Get("Accept-Encoding")
 	if strings.Contains(accept, "gzip") {
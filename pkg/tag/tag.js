@@ -4,6 +4,16 @@
   const location = window.location;
   const currentScript = document.currentScript;
   const api_url = currentScript.getAttribute('data-api') || getDefaultApiEndpoint(currentScript);
+  const domain = currentScript.getAttribute('data-domain') || location.hostname;
+  // a random id that recognises returning visitors is only sent when the page
+  // opts in, and is only kept by sites whose privacy allows it
+  const returning = currentScript.hasAttribute('data-returning') && navigator.doNotTrack !== '1';
+  // pages that were not found send a 404 event after their pageview
+  var notFound = currentScript.hasAttribute('data-404');
+  // apps that route on the fragment have it kept in their urls
+  const hashMode = currentScript.hasAttribute('data-hash');
+  // in manual mode the page sends its own pageviews with g('pageview')
+  const manual = currentScript.hasAttribute('data-manual');
   
   function getDefaultApiEndpoint(script) {
     return new URL(script.src).origin + '/e';
@@ -14,6 +24,26 @@
      options && options.callback && options.callback();
   }
 
+  function getVisitorId() {
+    try {
+      var id = localStorage.getItem('gotrack_id');
+      if (!id) {
+        var bytes = new Uint8Array(16);
+        crypto.getRandomValues(bytes);
+        id = Array.prototype.map.call(bytes, function(b) { return ('0' + b.toString(16)).slice(-2) }).join('');
+        localStorage.setItem('gotrack_id', id);
+      }
+      return id;
+    } catch (e) {
+      return undefined;
+    }
+  }
+
+  function isHeadless() {
+    return /HeadlessChrome|PhantomJS/.test(navigator.userAgent) ||
+      !!(window._phantom || window.callPhantom || window.__nightmare);
+  }
+
   function sendEvent(eventName, options) {
     {{- if not .IsDebug -}}
     if (/^localhost$|^127(\.[0-9]+){0,2}\.[0-9]+$|^\[::1?\]$/.test(location.hostname) || location.protocol === 'file:') {
@@ -22,23 +52,46 @@
     }
     {{- end -}}
     var payload = {};
+    payload.d = domain;
     payload.n = eventName;
     payload.u = location.href;
+    // a url the page is tracked as instead, which may be relative
+    payload.o = options && options.u || undefined;
+    payload.hr = hashMode || undefined;
     payload.r = document.referrer || undefined;
     if (options && options.props) {
       payload.p = options.props;
     }
+    if (eventName === '404') {
+      payload.p = payload.p || {};
+      payload.p.url = payload.p.url || location.href;
+    }
     payload.v = window.innerWidth + 'x' + window.innerHeight;
+    payload.w = navigator.webdriver || undefined;
+    payload.h = isHeadless() || undefined;
+    payload.i = returning ? getVisitorId() : undefined;
+    if (eventName === 'engagement') {
+      payload.e = options.e;
+      payload.sd = options.sd;
+    }
     {{- if .IncludeRevenue -}}
     if (options && options.$) {
       payload.$ = options.$;
     }
     {{- end -}}
 
+    const body = JSON.stringify(payload);
+    if (options && options.beacon && navigator.sendBeacon) {
+      // a beacon outlives the page, and is sent as text/plain so it needs no
+      // preflight
+      navigator.sendBeacon(api_url, body);
+      return;
+    }
+
     const request = new XMLHttpRequest();
     request.open('POST', api_url, true);
     request.setRequestHeader('Content-Type', 'application/json');
-    request.send(JSON.stringify(payload));
+    request.send(body);
     request.onreadystatechange = function() {
       if (request.readyState === 4) {
         if (request.status !== 202) {
@@ -50,29 +103,116 @@
     };
   }
 
-  window.g = window.g || sendEvent;
+  // pageviews sent by the page go through pageView so their engagement is
+  // tracked too
+  function track(eventName, options) {
+    if (eventName === 'pageview') {
+      pageView(options);
+    } else {
+      sendEvent(eventName, options);
+    }
+  }
 
-  var lastpage;
-  function pageView() {
-    lastpage = location.pathname
-    sendEvent('pageview')
+  window.g = window.g || track;
+
+  // engagement is the time a page is visible and focused and how far it is
+  // scrolled, it is sent when the page is hidden or left
+  var engagementUrl, engagedSince = 0, engagedTime = 0, scrollDepth = 0;
+
+  function startEngagement() {
+    if (!engagedSince && document.visibilityState === 'visible' && document.hasFocus()) {
+      engagedSince = Date.now();
+    }
   }
 
-  var windowHistoryPushState = window.history.pushState;
-  window.history.pushState = function(data, title, url) {
-    windowHistoryPushState.apply(this, [data, title, url]);
-    pageView();
+  function stopEngagement() {
+    if (engagedSince) {
+      engagedTime += Date.now() - engagedSince;
+      engagedSince = 0;
+    }
   }
-  window.addEventListener('popstate', pageView);
 
-  if (document.visibilityState !== 'visible') {
-    document.addEventListener('visibilitychange', function() {
-      if (!lastpage && document.visibilityState === 'visible') {
-        pageView()
-      }
-    })
-  } else {
-    pageView()
+  function updateScrollDepth() {
+    var el = document.documentElement;
+    var body = document.body || el;
+    var height = Math.max(el.scrollHeight, body.scrollHeight, el.offsetHeight, body.offsetHeight);
+    var bottom = (window.scrollY || el.scrollTop) + window.innerHeight;
+    var depth = height > 0 ? Math.min(100, Math.round(bottom / height * 100)) : 100;
+    if (depth > scrollDepth) {
+      scrollDepth = depth;
+    }
+  }
+
+  function sendEngagement() {
+    stopEngagement();
+    if (engagementUrl && engagedTime > 0) {
+      sendEvent('engagement', { u: engagementUrl, e: engagedTime, sd: scrollDepth, beacon: true });
+      engagedTime = 0;
+    }
+  }
+
+  document.addEventListener('visibilitychange', function() {
+    if (document.visibilityState === 'hidden') {
+      sendEngagement();
+    } else {
+      startEngagement();
+    }
+  });
+  window.addEventListener('pagehide', sendEngagement);
+  window.addEventListener('focus', startEngagement);
+  window.addEventListener('blur', stopEngagement);
+  document.addEventListener('scroll', updateScrollDepth, { passive: true });
+
+  // currentPage is the part of the location that tells pages apart, moving
+  // within a page is not a pageview
+  function currentPage() {
+    return location.pathname + (hashMode ? location.hash : '');
+  }
+
+  function navigated() {
+    if (currentPage() !== lastpage) {
+      pageView();
+    }
+  }
+
+  var lastpage;
+  function pageView(options) {
+    // the engagement of the page being left is sent with its url
+    sendEngagement();
+    lastpage = currentPage();
+    // resolved now, as the location may change before it is sent
+    engagementUrl = options && options.u ? new URL(options.u, location.href).href : location.href;
+    engagedTime = 0;
+    scrollDepth = 0;
+    updateScrollDepth();
+    startEngagement();
+    sendEvent('pageview', options)
+    if (notFound) {
+      notFound = false;
+      sendEvent('404');
+    }
+  }
+
+  if (!manual) {
+    var windowHistoryPushState = window.history.pushState;
+    window.history.pushState = function(data, title, url) {
+      windowHistoryPushState.apply(this, [data, title, url]);
+      navigated();
+    }
+    window.addEventListener('popstate', navigated);
+    if (hashMode) {
+      window.addEventListener('hashchange', navigated);
+    }
+
+    if (document.visibilityState !== 'visible') {
+      document.addEventListener('visibilitychange', function() {
+        if (!lastpage && document.visibilityState === 'visible') {
+          pageView()
+        }
+      })
+    } else {
+      pageView()
+    }
   }
 
   {{- if .IncludeAll -}}